@@ -0,0 +1,81 @@
+package engine
+
+// actionRun steps through an action list. Steps that finish later, such as
+// waiting for a program or a video, pause the run and resume it from the
+// main loop, so the player keeps drawing in the meantime.
+type actionRun struct {
+	e       *Engine
+	element Element // The element the actions belong to
+	actions []Action
+	next    int
+	done    bool
+	after   func() // Called once the run is done
+}
+
+// runActions starts running actions for element and returns the run. Steps
+// up to the first one that has to wait run before it returns.
+func (e *Engine) runActions(element Element, actions []Action) *actionRun {
+	r := &actionRun{e: e, element: element, actions: actions}
+	r.resume()
+	return r
+}
+
+func (r *actionRun) resume() {
+	for r.next < len(r.actions) {
+		action := r.actions[r.next]
+		r.next++
+		if action.If != "" {
+			// Continue with the chosen branch, then the rest of the list.
+			branch := r.e.branch(action)
+			r.actions = append(branch, r.actions[r.next:]...)
+			r.next = 0
+			continue
+		}
+		if r.e.runTrigger(action.apply(r.element), r.resume) {
+			return
+		}
+	}
+	r.done = true
+	if r.after != nil {
+		r.after()
+	}
+}
+
+// branch returns the steps an if action continues with.
+func (e *Engine) branch(action Action) []Action {
+	if !e.condition(action.If) {
+		return append([]Action(nil), action.Else...)
+	}
+	var steps []Action
+	if action.Trigger != "" {
+		step := action
+		step.If, step.Then, step.Else = "", nil, nil
+		steps = append(steps, step)
+	}
+	return append(steps, action.Then...)
+}
+
+// actionList returns the element's actions. An element with only the
+// single trigger fields gets a one-step list.
+func (element Element) actionList() []Action {
+	if len(element.Actions) > 0 {
+		return element.Actions
+	}
+	if element.Trigger == "" {
+		return nil
+	}
+	return []Action{{
+		Trigger:           element.Trigger,
+		TriggerTarget:     element.TriggerTarget,
+		TriggerValue:      element.TriggerValue,
+		ExternalAppReturn: element.ExternalAppReturn,
+		Background:        element.Background,
+		OnReturn:          element.OnReturn,
+		Args:              element.Args,
+		Cwd:               element.Cwd,
+		Env:               element.Env,
+		Shell:             element.Shell,
+		Job:               element.Job,
+		Timeout:           element.Timeout,
+	}}
+}
@@ -0,0 +1,277 @@
+package engine
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxConsoleLines is how much output a console keeps; older lines are
+// dropped.
+const maxConsoleLines = 1000
+
+// termColor is a color set by an escape sequence. The zero value is the
+// element's own color.
+type termColor struct {
+	R, G, B uint8
+	Set     bool
+}
+
+// termSpan is a run of text in a single color.
+type termSpan struct {
+	text  []byte
+	color termColor
+}
+
+// termBuffer turns program output into lines of colored text. It
+// understands the SGR sequences that set the foreground color (16 colors,
+// the 256-color palette and 24-bit colors) and bold, which brightens the
+// 8 basic colors. Other escape sequences are dropped, and a carriage return
+// starts the line over, so progress bars redraw in place.
+type termBuffer struct {
+	lines   [][]termSpan // Finished lines, oldest first
+	current []termSpan
+
+	fg       int // Palette index, -1 for the default color
+	rgb      termColor
+	bold     bool
+	overtype bool   // A carriage return was seen; the next text replaces the line
+	rest     []byte // Incomplete escape sequence or character from the last write
+}
+
+func newTermBuffer() *termBuffer {
+	return &termBuffer{fg: -1}
+}
+
+// write adds output to the buffer. Sequences split across writes are
+// completed by the next one.
+func (t *termBuffer) write(p []byte) {
+	data := append(t.rest, p...)
+	t.rest = nil
+
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case c == 0x1b:
+			n, ok := escapeLength(data[i:])
+			if !ok {
+				t.rest = append([]byte(nil), data[i:]...)
+				return
+			}
+			if seq := data[i : i+n]; len(seq) > 2 && seq[1] == '[' && seq[n-1] == 'm' {
+				t.sgr(string(seq[2 : n-1]))
+			}
+			i += n
+			continue
+		case c == '\n':
+			t.newline()
+		case c == '\r':
+			t.overtype = true
+		case c == '\t':
+			t.text([]byte(strings.Repeat(" ", 8-t.width()%8)))
+		case c < 0x20 || c == 0x7f:
+			// Other control characters draw nothing.
+		default:
+			if !utf8.FullRune(data[i:]) {
+				t.rest = append([]byte(nil), data[i:]...)
+				return
+			}
+			_, size := utf8.DecodeRune(data[i:])
+			t.text(data[i : i+size])
+			i += size
+			continue
+		}
+		i++
+	}
+}
+
+// escapeLength returns the length of the escape sequence seq starts with,
+// or false if it is incomplete.
+func escapeLength(seq []byte) (int, bool) {
+	if len(seq) < 2 {
+		return 0, false
+	}
+	switch seq[1] {
+	case '[': // CSI: parameters up to a final byte
+		for i := 2; i < len(seq); i++ {
+			if seq[i] >= 0x40 && seq[i] <= 0x7e {
+				return i + 1, true
+			}
+		}
+	case ']': // OSC: up to BEL or ESC \
+		for i := 2; i < len(seq); i++ {
+			if seq[i] == 0x07 {
+				return i + 1, true
+			}
+			if seq[i] == 0x1b && i+1 < len(seq) && seq[i+1] == '\\' {
+				return i + 2, true
+			}
+		}
+	default:
+		return 2, true
+	}
+	if len(seq) > 256 {
+		return len(seq), true // Not a sequence after all; drop it
+	}
+	return 0, false
+}
+
+// sgr applies a Select Graphic Rendition sequence's parameters.
+func (t *termBuffer) sgr(params string) {
+	codes := strings.Split(params, ";")
+	for i := 0; i < len(codes); i++ {
+		code, _ := strconv.Atoi(codes[i]) // Empty means 0
+		switch {
+		case code == 0:
+			t.fg, t.rgb, t.bold = -1, termColor{}, false
+		case code == 1:
+			t.bold = true
+		case code == 22:
+			t.bold = false
+		case code >= 30 && code <= 37:
+			t.fg, t.rgb = code-30, termColor{}
+		case code >= 90 && code <= 97:
+			t.fg, t.rgb = code-90+8, termColor{}
+		case code == 39:
+			t.fg, t.rgb = -1, termColor{}
+		case code == 38 || code == 48:
+			// Extended color: 5;n or 2;r;g;b. Background colors are skipped.
+			if i+1 >= len(codes) {
+				return
+			}
+			mode, _ := strconv.Atoi(codes[i+1])
+			args := 0
+			switch mode {
+			case 5:
+				args = 1
+			case 2:
+				args = 3
+			}
+			if i+1+args >= len(codes) {
+				return
+			}
+			if code == 38 {
+				n := make([]uint8, args)
+				for j := range n {
+					v, _ := strconv.Atoi(codes[i+2+j])
+					n[j] = uint8(v)
+				}
+				switch mode {
+				case 5:
+					t.fg, t.rgb = int(n[0]), termColor{}
+				case 2:
+					t.fg, t.rgb = -1, termColor{R: n[0], G: n[1], B: n[2], Set: true}
+				}
+			}
+			i += 1 + args
+		}
+	}
+}
+
+// color is the color text written now gets.
+func (t *termBuffer) color() termColor {
+	if t.rgb.Set {
+		return t.rgb
+	}
+	if t.fg < 0 {
+		return termColor{}
+	}
+	index := t.fg
+	if t.bold && index < 8 {
+		index += 8
+	}
+	return paletteColor(index)
+}
+
+// ansiPalette is the 16 basic colors as xterm draws them.
+var ansiPalette = [16][3]uint8{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// paletteColor returns a color of the 256-color palette.
+func paletteColor(index int) termColor {
+	switch {
+	case index < 16:
+		c := ansiPalette[index]
+		return termColor{R: c[0], G: c[1], B: c[2], Set: true}
+	case index < 232: // 6×6×6 cube
+		index -= 16
+		level := func(n int) uint8 {
+			if n == 0 {
+				return 0
+			}
+			return uint8(55 + n*40)
+		}
+		return termColor{R: level(index / 36), G: level(index / 6 % 6), B: level(index % 6), Set: true}
+	default: // Grays
+		v := uint8(8 + (index-232)*10)
+		return termColor{R: v, G: v, B: v, Set: true}
+	}
+}
+
+func (t *termBuffer) text(s []byte) {
+	if t.overtype {
+		t.current, t.overtype = nil, false
+	}
+	color := t.color()
+	if n := len(t.current); n > 0 && t.current[n-1].color == color {
+		t.current[n-1].text = append(t.current[n-1].text, s...)
+		return
+	}
+	t.current = append(t.current, termSpan{text: append([]byte(nil), s...), color: color})
+}
+
+func (t *termBuffer) newline() {
+	t.lines = append(t.lines, t.current)
+	t.current, t.overtype = nil, false
+	if extra := len(t.lines) - maxConsoleLines; extra > 0 {
+		t.lines = append(t.lines[:0:0], t.lines[extra:]...)
+	}
+}
+
+// width returns the current line's length in characters.
+func (t *termBuffer) width() int {
+	n := 0
+	for _, span := range t.current {
+		n += utf8.RuneCount(span.text)
+	}
+	return n
+}
+
+// allLines returns the finished lines and the one being written, if any.
+func (t *termBuffer) allLines() [][]termSpan {
+	if len(t.current) == 0 {
+		return t.lines
+	}
+	return append(t.lines[:len(t.lines):len(t.lines)], t.current)
+}
+
+// wrapLine splits a line into rows of at most columns characters.
+func wrapLine(line []termSpan, columns int) [][]termSpan {
+	columns = max(columns, 1)
+	rows := [][]termSpan{nil}
+	used := 0
+	for _, span := range line {
+		text := span.text
+		for len(text) > 0 {
+			if used == columns {
+				rows = append(rows, nil)
+				used = 0
+			}
+			// Take as many characters as fit in the row.
+			cut, n := 0, 0
+			for cut < len(text) && used+n < columns {
+				_, size := utf8.DecodeRune(text[cut:])
+				cut += size
+				n++
+			}
+			rows[len(rows)-1] = append(rows[len(rows)-1], termSpan{text: text[:cut], color: span.color})
+			text = text[cut:]
+			used += n
+		}
+	}
+	return rows
+}
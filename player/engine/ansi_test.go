@@ -0,0 +1,129 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestTermBuffer(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string // Handed to write one at a time
+		want   string   // Lines, with <r,g,b> before colored spans
+	}{
+		{"plain", []string{"one\ntwo"}, "one\ntwo"},
+		{"basic color", []string{"a\x1b[31mred\x1b[0mb"}, "a<205,0,0>redb"},
+		{"bright color", []string{"\x1b[92mgreen"}, "<0,255,0>green"},
+		{"bold brightens", []string{"\x1b[1;34mblue\x1b[22mdim"}, "<92,92,255>blue<0,0,238>dim"},
+		{"bold leaves bright alone", []string{"\x1b[1;94mblue"}, "<92,92,255>blue"},
+		{"reset", []string{"\x1b[1;31mred\x1b[0mplain\x1b[mplain"}, "<255,0,0>redplainplain"},
+		{"default color", []string{"\x1b[33my\x1b[39mplain"}, "<205,205,0>yplain"},
+		{"color carries over lines", []string{"\x1b[32ma\nb"}, "<0,205,0>a\n<0,205,0>b"},
+
+		// Extended colors
+		{"256 basic", []string{"\x1b[38;5;9mx"}, "<255,0,0>x"},
+		{"256 cube", []string{"\x1b[38;5;196mx\x1b[38;5;16my\x1b[38;5;231mz"}, "<255,0,0>x<0,0,0>y<255,255,255>z"},
+		{"256 gray", []string{"\x1b[38;5;232mx\x1b[38;5;255my"}, "<8,8,8>x<238,238,238>y"},
+		{"truecolor", []string{"\x1b[38;2;10;20;30mx"}, "<10,20,30>x"},
+		{"truecolor then reset", []string{"\x1b[38;2;10;20;30mx\x1b[0my"}, "<10,20,30>xy"},
+		{"background skipped", []string{"\x1b[48;5;21;31mx\x1b[48;2;1;2;3;32my"}, "<205,0,0>x<0,205,0>y"},
+		{"truncated extended color", []string{"\x1b[38;2;10mx"}, "x"},
+
+		// Sequences split across writes
+		{"split after ESC", []string{"a\x1b", "[31mb"}, "a<205,0,0>b"},
+		{"split in parameters", []string{"a\x1b[38;2;", "1;2;3mb"}, "a<1,2,3>b"},
+		{"split in three", []string{"\x1b", "[3", "1mx"}, "<205,0,0>x"},
+		{"split UTF-8", []string{"caf\xc3", "\xa9"}, "café"},
+
+		// Carriage returns
+		{"overtype", []string{"10%\r50%\r100%\n"}, "100%"},
+		{"overtype across writes", []string{"10%\r", "50%"}, "50%"},
+		{"CRLF", []string{"a\r\nb\r\n"}, "a\nb"},
+		{"CR keeps color", []string{"\x1b[31m1\r2"}, "<205,0,0>2"},
+
+		// Everything else
+		{"unknown CSI", []string{"a\x1b[2Kb\x1b[10;5Hc\x1b[?25ld"}, "abcd"},
+		{"OSC title", []string{"\x1b]0;title\x07a\x1b]2;t\x1b\\b"}, "ab"},
+		{"two-byte escape", []string{"a\x1b=b\x1b>c"}, "abc"},
+		{"controls", []string{"a\x00\x07\x08b\x7f"}, "ab"},
+		{"tab", []string{"ab\tc"}, "ab      c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			term := newTermBuffer()
+			for _, w := range tt.writes {
+				term.write([]byte(w))
+			}
+			if got := dumpTerm(term); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestTermBufferRunaway checks that an escape sequence that never ends is
+// dropped rather than holding back the output after it for good.
+func TestTermBufferRunaway(t *testing.T) {
+	term := newTermBuffer()
+	term.write([]byte("a\x1b[" + strings.Repeat("1;", 200)))
+	term.write([]byte("b"))
+	if got := dumpTerm(term); got != "ab" {
+		t.Errorf("got %q, want %q", got, "ab")
+	}
+}
+
+func TestTermBufferMaxLines(t *testing.T) {
+	term := newTermBuffer()
+	for i := 0; i < maxConsoleLines+10; i++ {
+		term.write([]byte(fmt.Sprintf("%d\n", i)))
+	}
+	lines := term.allLines()
+	if len(lines) != maxConsoleLines {
+		t.Fatalf("%d lines, want %d", len(lines), maxConsoleLines)
+	}
+	if first := string(lines[0][0].text); first != "10" {
+		t.Errorf("first line is %q, want 10", first)
+	}
+}
+
+func TestWrapLine(t *testing.T) {
+	red := termColor{R: 205, Set: true}
+	line := []termSpan{{text: []byte("abc")}, {text: []byte("défg"), color: red}}
+	tests := []struct {
+		columns int
+		want    string
+	}{
+		{10, "abc<205,0,0>défg"},
+		{7, "abc<205,0,0>défg"},
+		{4, "abc<205,0,0>d\n<205,0,0>éfg"},
+		{3, "abc\n<205,0,0>déf\n<205,0,0>g"},
+		{0, "a\nb\nc\n<205,0,0>d\n<205,0,0>é\n<205,0,0>f\n<205,0,0>g"}, // At least one column
+	}
+	for _, tt := range tests {
+		if got := dumpRows(wrapLine(line, tt.columns)); got != tt.want {
+			t.Errorf("%d columns: got %q, want %q", tt.columns, got, tt.want)
+		}
+	}
+}
+
+func dumpTerm(term *termBuffer) string {
+	return dumpRows(term.allLines())
+}
+
+// dumpRows writes rows one per line, with <r,g,b> before colored spans.
+func dumpRows(rows [][]termSpan) string {
+	var b strings.Builder
+	for i, row := range rows {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		for _, span := range row {
+			if span.color.Set {
+				fmt.Fprintf(&b, "<%d,%d,%d>", span.color.R, span.color.G, span.color.B)
+			}
+			b.Write(span.text)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,240 @@
+package engine
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"github.com/veandco/go-sdl2/ttf"
+)
+
+// approval is the question shown before an app may run its commands.
+type approval struct {
+	commands []string
+	scroll   int
+	then     func() // Runs once the user agrees
+}
+
+// appCommands lists the commands config can run, as written, in the order
+// they appear. The approval dialog shows them. An external_app's cwd and env
+// are shown the way a shell would set them.
+func appCommands(config *Config) []string {
+	var commands []string
+	add := func(command string) {
+		if command != "" && !slices.Contains(commands, command) {
+			commands = append(commands, command)
+		}
+	}
+	addActions := func(actions []Action) {
+		for _, action := range flattenActions(actions) {
+			switch action.Trigger {
+			case "external_app":
+				add(describeApp(action))
+			case "play_video":
+				add("ffplay " + action.TriggerTarget)
+			}
+		}
+	}
+
+	for _, scene := range config.Scenes {
+		for _, list := range sceneActions(scene) {
+			addActions(list.actions)
+		}
+		for _, element := range scene.Elements {
+			switch element.Type {
+			case "collapsedlist", "dynamiclist", "console":
+				if element.Command != "" {
+					add("sh -c " + element.Command)
+				}
+			case "video":
+				if element.Video != "" {
+					add("ffmpeg/ffplay " + element.Video)
+				}
+			}
+			addActions(element.actionList())
+		}
+	}
+	return commands
+}
+
+// describeApp writes out the command an external_app runs, with its
+// working directory and environment.
+func describeApp(action Action) string {
+	var words []string
+	if action.Cwd != "" {
+		words = append(words, "cd", action.Cwd, "&&")
+	}
+	names := make([]string, 0, len(action.Env))
+	for name := range action.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		words = append(words, name+"="+action.Env[name])
+	}
+	if action.Shell {
+		words = append(words, "sh -c")
+	}
+	words = append(words, action.TriggerTarget)
+	return strings.Join(append(words, action.Args...), " ")
+}
+
+// programFromVariables reports whether which program an external_app runs
+// depends on variables: its name, the directory a relative name is found
+// in or $PATH. The approval dialog couldn't show what it is, so apps like
+// this are refused while commands need confirming.
+func programFromVariables(shell bool, program, cwd string, env map[string]string) bool {
+	if shell {
+		return false // The script is shown, and values in it are quoted
+	}
+	return strings.Contains(program, "$") || strings.Contains(cwd, "$") || strings.Contains(env["PATH"], "$")
+}
+
+// SetApprovalFile makes the engine remember in the file at path which
+// commands the user has allowed, so it only asks again when they change.
+// Without one it asks on every launch. Keep the file out of the app's own
+// directory, or an app could ship with its commands already allowed. Call
+// it before Run.
+func (e *Engine) SetApprovalFile(path string) {
+	e.approvalPath = path
+}
+
+// approvedCommands returns the commands allowed on an earlier run.
+func (e *Engine) approvedCommands() []string {
+	if e.approvalPath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(e.approvalPath)
+	if err != nil {
+		return nil
+	}
+	var saved struct {
+		Commands []string `json:"commands"`
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		configLog.Warn("ignoring unreadable approval file", "path", e.approvalPath, "err", err)
+		return nil
+	}
+	return saved.Commands
+}
+
+// requestApproval calls then right away unless a policy asks the user to
+// allow the app's commands and they haven't allowed these ones yet. In that
+// case the approval dialog takes over the screen, and no command runs,
+// until the user answers.
+func (e *Engine) requestApproval(then func()) {
+	commands := appCommands(e.config)
+	if !e.confirmCommands() || len(commands) == 0 || slices.Equal(commands, e.approvedCommands()) {
+		if then != nil {
+			then()
+		}
+		return
+	}
+	processLog.Info("asking the user to allow the app's commands", "commands", len(commands))
+	e.approval = &approval{commands: commands, then: then}
+	e.Invalidate()
+}
+
+// handleApprovalAction answers the approval dialog: confirm allows the
+// commands, back quits the player.
+func (e *Engine) handleApprovalAction(action InputAction) {
+	a := e.approval
+	switch action {
+	case InputUp:
+		a.scroll = max(a.scroll-1, 0)
+	case InputDown:
+		a.scroll = min(a.scroll+1, len(a.commands)-1)
+	case InputConfirm:
+		e.approval = nil
+		if e.approvalPath != "" {
+			saved := map[string]interface{}{"commands": a.commands}
+			if err := writeFileAtomic(e.approvalPath, saved); err != nil {
+				configLog.Error("cannot save approval", "path", e.approvalPath, "err", err)
+			}
+		}
+		if a.then != nil {
+			a.then()
+		}
+	case InputBack:
+		e.Stop()
+	}
+}
+
+func (e *Engine) renderApproval() {
+	a := e.approval
+	renderer := e.renderer
+	screenWidth, screenHeight := e.LogicalSize()
+	renderer.SetDrawColor(24, 24, 24, 255)
+	renderer.FillRect(&sdl.Rect{X: 0, Y: 0, W: screenWidth, H: screenHeight})
+
+	font, _ := e.getFontAndSize("medium")
+	small, _ := e.getFontAndSize("small")
+	if font == nil || small == nil {
+		return
+	}
+	white := sdl.Color{R: 255, G: 255, B: 255, A: 255}
+	gray := sdl.Color{R: 170, G: 170, B: 170, A: 255}
+	yellow := sdl.Color{R: 230, G: 200, B: 80, A: 255}
+	const margin = 40
+
+	title := e.config.Title
+	if title == "" {
+		title = "This app"
+	}
+	y := int32(margin)
+	_, h := getTextDimensions(font, title)
+	e.renderPlainText(font, title+" wants to run these commands:", white, margin, y)
+	y += h + margin/2
+
+	// Commands that use variables are highlighted: what they do depends on
+	// values only known when they run.
+	flagged := slices.ContainsFunc(a.commands, func(command string) bool { return strings.Contains(command, "$") })
+	footer := []string{"Confirm to allow them, Back to quit. Up and down scroll the list."}
+	if flagged {
+		footer = append([]string{"Yellow commands use variables, so what they run can change."}, footer...)
+	}
+
+	_, lineHeight := getTextDimensions(small, "M")
+	footerHeight := int32(len(footer)) * lineHeight
+	visible := max(int((screenHeight-y-footerHeight-2*margin)/max(lineHeight, 1)), 1)
+	end := min(a.scroll+visible, len(a.commands))
+	for _, command := range a.commands[a.scroll:end] {
+		color := gray
+		if strings.Contains(command, "$") {
+			color = yellow
+		}
+		command = strings.ReplaceAll(command, "\n", " ")
+		e.renderPlainText(small, fitText(small, command, screenWidth-2*margin), color, margin, y)
+		y += lineHeight
+	}
+	if end < len(a.commands) {
+		e.renderPlainText(small, "...", gray, margin, y)
+	}
+
+	y = screenHeight - margin - footerHeight
+	for _, line := range footer {
+		e.renderPlainText(small, line, white, margin, y)
+		y += lineHeight
+	}
+}
+
+// fitText shortens text with "..." until it is at most width wide.
+func fitText(font *ttf.Font, text string, width int32) string {
+	if w, _ := getTextDimensions(font, text); w <= width {
+		return text
+	}
+	runes := []rune(text)
+	lo, hi := 0, len(runes) // The longest prefix that fits is in [lo, hi)
+	for lo+1 < hi {
+		mid := (lo + hi) / 2
+		if w, _ := getTextDimensions(font, string(runes[:mid])+"..."); w <= width {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return string(runes[:lo]) + "..."
+}
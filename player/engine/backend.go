@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Backend owns the SDL renderer the engine draws into. The engine only ever
+// talks to the renderer it returns, so the same scene code runs on screen
+// and offscreen.
+type Backend interface {
+	Renderer() *sdl.Renderer
+	Size() (width, height int32)
+	Present()
+	Destroy()
+}
+
+// windowBackend draws into a real window with a hardware renderer.
+type windowBackend struct {
+	window   *sdl.Window
+	renderer *sdl.Renderer
+}
+
+func newWindowBackend(title string, width, height int32, fullscreen, vsync bool) (*windowBackend, error) {
+	windowFlags := uint32(sdl.WINDOW_SHOWN)
+	if fullscreen {
+		windowFlags |= sdl.WINDOW_FULLSCREEN_DESKTOP
+	}
+	window, err := sdl.CreateWindow(title, sdl.WINDOWPOS_CENTERED, sdl.WINDOWPOS_CENTERED, width, height, windowFlags)
+	if err != nil {
+		return nil, fmt.Errorf("creating window: %w", err)
+	}
+
+	flags := uint32(sdl.RENDERER_ACCELERATED)
+	if vsync {
+		flags |= sdl.RENDERER_PRESENTVSYNC
+	}
+	renderer, err := sdl.CreateRenderer(window, -1, flags)
+	if err != nil {
+		window.Destroy()
+		return nil, fmt.Errorf("creating renderer: %w", err)
+	}
+	return &windowBackend{window: window, renderer: renderer}, nil
+}
+
+func (b *windowBackend) Renderer() *sdl.Renderer { return b.renderer }
+
+func (b *windowBackend) Size() (int32, int32) {
+	w, h, err := b.renderer.GetOutputSize()
+	if err != nil {
+		return b.window.GetSize()
+	}
+	return w, h
+}
+
+func (b *windowBackend) Present() { b.renderer.Present() }
+
+func (b *windowBackend) Destroy() {
+	b.renderer.Destroy()
+	b.window.Destroy()
+}
+
+// OffscreenBackend renders into an in-memory software surface. It needs no
+// display, so scenes can be rendered on a headless CI box and compared
+// against golden images.
+type OffscreenBackend struct {
+	surface  *sdl.Surface
+	renderer *sdl.Renderer
+}
+
+// NewOffscreenBackend creates a width x height software render target.
+func NewOffscreenBackend(width, height int32) (*OffscreenBackend, error) {
+	surface, err := sdl.CreateRGBSurfaceWithFormat(0, width, height, 32, uint32(sdl.PIXELFORMAT_RGBA32))
+	if err != nil {
+		return nil, fmt.Errorf("creating surface: %w", err)
+	}
+
+	renderer, err := sdl.CreateSoftwareRenderer(surface)
+	if err != nil {
+		surface.Free()
+		return nil, fmt.Errorf("creating software renderer: %w", err)
+	}
+	renderer.SetDrawBlendMode(sdl.BLENDMODE_BLEND)
+	return &OffscreenBackend{surface: surface, renderer: renderer}, nil
+}
+
+func (b *OffscreenBackend) Renderer() *sdl.Renderer { return b.renderer }
+
+func (b *OffscreenBackend) Size() (int32, int32) { return b.surface.W, b.surface.H }
+
+// Present is a no-op: the software renderer draws straight into the surface.
+func (b *OffscreenBackend) Present() {}
+
+func (b *OffscreenBackend) Destroy() {
+	b.renderer.Destroy()
+	b.surface.Free()
+}
+
+// Image copies the current contents of the surface into an RGBA image.
+func (b *OffscreenBackend) Image() *image.RGBA {
+	w, h := int(b.surface.W), int(b.surface.H)
+	pitch := int(b.surface.Pitch)
+	pixels := b.surface.Pixels()
+
+	rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		copy(rgba.Pix[y*rgba.Stride:y*rgba.Stride+w*4], pixels[y*pitch:y*pitch+w*4])
+	}
+	return rgba
+}
+
+// SavePNG writes the current contents of the surface to path.
+func (b *OffscreenBackend) SavePNG(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, b.Image()); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
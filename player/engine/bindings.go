@@ -0,0 +1,190 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// InputAction is a logical input. Keys and controller buttons are bound to
+// actions, and the engine only ever reacts to actions.
+type InputAction string
+
+const (
+	InputUp        InputAction = "up"
+	InputDown      InputAction = "down"
+	InputLeft      InputAction = "left"
+	InputRight     InputAction = "right"
+	InputConfirm   InputAction = "confirm"
+	InputBack      InputAction = "back"
+	InputPrevScene InputAction = "prevScene"
+	InputNextScene InputAction = "nextScene"
+	InputMenu      InputAction = "menu"
+)
+
+// InputBindings maps action names to SDL key names ("Return", "Q", "Up")
+// and controller button names ("a", "leftshoulder", "dpup"). An action
+// listed here replaces its default binding, and an empty list unbinds it; a
+// key or button bound to a new action loses its default one.
+type InputBindings struct {
+	Keyboard   map[string][]string `json:"keyboard"`
+	Controller map[string][]string `json:"controller"`
+}
+
+// defaultBindings follows the usual handheld layout: A confirms, B goes
+// back, the shoulders and Q/E change scene.
+var defaultBindings = InputBindings{
+	Keyboard: map[string][]string{
+		"up":        {"Up"},
+		"down":      {"Down"},
+		"left":      {"Left"},
+		"right":     {"Right"},
+		"confirm":   {"Return", "Space"},
+		"back":      {"Escape"},
+		"prevScene": {"Q"},
+		"nextScene": {"E"},
+		"menu":      {"Tab"},
+	},
+	Controller: map[string][]string{
+		"up":        {"dpup"},
+		"down":      {"dpdown"},
+		"left":      {"dpleft"},
+		"right":     {"dpright"},
+		"confirm":   {"a"},
+		"back":      {"b"},
+		"prevScene": {"leftshoulder"},
+		"nextScene": {"rightshoulder"},
+		"menu":      {"start"},
+	},
+}
+
+var inputActions = map[InputAction]bool{
+	InputUp: true, InputDown: true, InputLeft: true, InputRight: true,
+	InputConfirm: true, InputBack: true, InputPrevScene: true, InputNextScene: true, InputMenu: true,
+}
+
+// bindings is the lookup table built from the defaults and the config.
+type bindings struct {
+	keys    map[sdl.Keycode]InputAction
+	buttons map[uint8]InputAction // Keyed like ControllerButtonEvent.Button
+}
+
+// bindingProblem is a name in inputBindings that means nothing.
+type bindingProblem struct {
+	field   string
+	message string
+}
+
+// newBindings combines the default bindings with config's. Unknown actions,
+// keys and buttons are skipped and reported.
+func newBindings(config InputBindings) (*bindings, []bindingProblem) {
+	b := &bindings{
+		keys:    make(map[sdl.Keycode]InputAction),
+		buttons: make(map[uint8]InputAction),
+	}
+	var problems []bindingProblem
+
+	bindKey := func(name string, action InputAction) bool {
+		key := sdl.GetKeyFromName(name)
+		if key == sdl.K_UNKNOWN {
+			return false
+		}
+		b.keys[key] = action
+		return true
+	}
+	bindButton := func(name string, action InputAction) bool {
+		button := sdl.GameControllerGetButtonFromString(name)
+		if button == sdl.CONTROLLER_BUTTON_INVALID {
+			return false
+		}
+		b.buttons[uint8(button)] = action
+		return true
+	}
+	bind := func(device, noun string, defaults, custom map[string][]string, set func(string, InputAction) bool) {
+		add := func(name string, inputs []string) {
+			field := "inputBindings." + device + "." + name
+			if !inputActions[InputAction(name)] {
+				problems = append(problems, bindingProblem{field, "unknown input action"})
+				return
+			}
+			for _, input := range inputs {
+				if !set(input, InputAction(name)) {
+					problems = append(problems, bindingProblem{field, fmt.Sprintf("unknown %s %q", noun, input)})
+				}
+			}
+		}
+		for _, name := range sortedKeys(defaults) {
+			if custom[name] == nil {
+				add(name, defaults[name])
+			}
+		}
+		for _, name := range sortedKeys(custom) {
+			add(name, custom[name])
+		}
+	}
+	bind("keyboard", "key", defaultBindings.Keyboard, config.Keyboard, bindKey)
+	bind("controller", "button", defaultBindings.Controller, config.Controller, bindButton)
+	return b, problems
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// inputAction returns the action a key or button press is bound to.
+func (e *Engine) inputAction(event sdl.Event) (InputAction, bool) {
+	switch ev := event.(type) {
+	case *sdl.KeyboardEvent:
+		if ev.Type == sdl.KEYDOWN {
+			action, ok := e.bindings.keys[ev.Keysym.Sym]
+			return action, ok
+		}
+	case *sdl.ControllerButtonEvent:
+		if ev.Type == sdl.CONTROLLERBUTTONDOWN {
+			action, ok := e.bindings.buttons[ev.Button]
+			return action, ok
+		}
+	}
+	return "", false
+}
+
+// handleInputAction performs action on the active scene.
+func (e *Engine) handleInputAction(action InputAction) {
+	if e.handleConsoleAction(action) {
+		return
+	}
+	switch action {
+	case InputUp, InputLeft:
+		e.moveSelection(-1)
+	case InputDown, InputRight:
+		e.moveSelection(1)
+	case InputConfirm:
+		e.activateSelectedElement()
+	case InputBack:
+		e.popScene()
+	case InputPrevScene:
+		e.changeScene(-1)
+	case InputNextScene:
+		e.changeScene(1)
+	case InputMenu:
+		if !e.Scene().Modal {
+			e.enterScene(0)
+		}
+	}
+}
+
+// useBindings rebuilds the binding table from the config, logging names
+// that mean nothing.
+func (e *Engine) useBindings() {
+	b, problems := newBindings(e.config.Input)
+	for _, p := range problems {
+		inputLog.Warn(p.message, "field", p.field)
+	}
+	e.bindings = b
+}
@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// appCommand builds the command an external_app trigger runs. By default
+// triggerTarget is the program and every entry of args is one argument,
+// however many spaces its variables contain. With shell set, triggerTarget
+// is a sh script instead: variables in it are quoted, and args are its
+// positional parameters ($1, $2, ...). cwd and env are substituted too; env
+// is added to the player's environment. The command is killed when ctx is
+// done.
+func (e *Engine) appCommand(ctx context.Context, element Element) (*exec.Cmd, error) {
+	config := e.config
+	if e.confirmCommands() && programFromVariables(element.Shell, element.TriggerTarget, element.Cwd, element.Env) {
+		return nil, errors.New("the program depends on variables, which is not allowed while commands need confirming")
+	}
+	args := make([]string, len(element.Args))
+	for i, arg := range element.Args {
+		args[i] = substituteVariables(arg, config)
+	}
+
+	var cmd *exec.Cmd
+	if element.Shell {
+		script := substituteVariablesWith(element.TriggerTarget, config, shellQuote)
+		cmd = exec.CommandContext(ctx, "sh", append([]string{"-c", script, "sh"}, args...)...)
+	} else {
+		program := substituteVariables(element.TriggerTarget, config)
+		if program == "" {
+			return nil, errors.New("no program to run")
+		}
+		cmd = exec.CommandContext(ctx, program, args...)
+	}
+
+	cmd.Dir = substituteVariables(element.Cwd, config)
+	if len(element.Env) > 0 {
+		names := make([]string, 0, len(element.Env))
+		for name := range element.Env {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		cmd.Env = os.Environ()
+		for _, name := range names {
+			cmd.Env = append(cmd.Env, name+"="+substituteVariables(element.Env[name], config))
+		}
+	}
+	return cmd, nil
+}
+
+// shellQuote quotes s as a single sh word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// appOptions returns the names of the external_app options action sets.
+func appOptions(action Action) []string {
+	var options []string
+	if action.Background {
+		options = append(options, "background")
+	}
+	if len(action.OnReturn) > 0 {
+		options = append(options, "onReturn")
+	}
+	if len(action.Args) > 0 {
+		options = append(options, "args")
+	}
+	if action.Cwd != "" {
+		options = append(options, "cwd")
+	}
+	if len(action.Env) > 0 {
+		options = append(options, "env")
+	}
+	if action.Shell {
+		options = append(options, "shell")
+	}
+	if action.Job != "" {
+		options = append(options, "job")
+	}
+	if action.Timeout != 0 {
+		options = append(options, "timeout")
+	}
+	return options
+}
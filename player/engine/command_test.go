@@ -0,0 +1,39 @@
+package engine
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"", "''"},
+		{"plain", "'plain'"},
+		{"My Game (USA).zip", "'My Game (USA).zip'"},
+		{"it's", `'it'\''s'`},
+		{"''", `''\'''\'''`},
+		{`$HOME "x" \n`, `'$HOME "x" \n'`},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.s); got != tt.want {
+			t.Errorf("shellQuote(%q) = %s, want %s", tt.s, got, tt.want)
+		}
+	}
+
+	// Whatever the text, the shell reads it back as one word.
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	for _, s := range []string{"", "a b", "it's", "$(touch x); `id` *", "line\nbreak", `\'"`} {
+		out, err := exec.Command("sh", "-c", "printf %s "+shellQuote(s)+"; printf '|%s' "+shellQuote(s)+" end").Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := s + "|" + s + "|end"; string(out) != want {
+			t.Errorf("sh read %q back as %q", s, out)
+		}
+	}
+}
@@ -0,0 +1,473 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// StringOrInt accepts either a JSON string or a JSON number (the generator
+// writes both for widths and heights).
+type StringOrInt string
+
+// Config is the decoded contents of a jukaconfig.json file.
+type Config struct {
+	Title       string           `json:"title"`
+	Author      string           `json:"author"`
+	Description string           `json:"description"`
+	Resolution  Resolution       `json:"resolution"`
+	Watch       bool             `json:"watch"` // Reload the file when it changes
+	SystemInfo  SystemInfoConfig `json:"systemInfo"`
+	Input       InputBindings    `json:"inputBindings"`
+	Security    *Policy          `json:"security"` // Limits the app sets for itself, see Policy
+	Variables   Variables        `json:"variables"`
+	Scenes      []SceneConfig    `json:"scenes"`
+
+	// Custom variables saved across launches, in addition to those written
+	// by elements and actions marked persist.
+	PersistentVariables []string `json:"persistentVariables"`
+}
+
+// Default logical screen size, the TrimUI Smart Pro's display.
+const (
+	defaultWidth  = 1280
+	defaultHeight = 720
+)
+
+// Resolution is the logical coordinate space element positions are written
+// in. The player scales it to whatever the display really is.
+type Resolution struct {
+	Width  int32  `json:"width"`
+	Height int32  `json:"height"`
+	Scale  string `json:"scale"` // "letterbox" (default), "stretch" or "integer"
+}
+
+// Scale modes for Resolution.Scale.
+const (
+	ScaleLetterbox = "letterbox" // Keep the aspect ratio, pad with black bars
+	ScaleStretch   = "stretch"   // Fill the whole output, distorting if needed
+	ScaleInteger   = "integer"   // Letterbox, but only scale by whole numbers
+)
+
+// LogicalSize returns the config's resolution, falling back to 1280x720.
+func (c *Config) LogicalSize() (int32, int32) {
+	w, h := c.Resolution.Width, c.Resolution.Height
+	if w <= 0 || h <= 0 {
+		return defaultWidth, defaultHeight
+	}
+	return w, h
+}
+
+type Variables struct {
+	ButtonColor struct {
+		R int `json:"r"`
+		G int `json:"g"`
+		B int `json:"b"`
+	} `json:"buttonColor"`
+	LabelColor struct {
+		R int `json:"r"`
+		G int `json:"g"`
+		B int `json:"b"`
+	} `json:"labelColor"`
+	BackgroundImage string            `json:"backgroundImage"`
+	Fonts           map[string]string `json:"fonts"`
+	FontSizes       map[string]int    `json:"fontSizes"`
+	Custom          map[string]interface{}
+	System          map[string]interface{} `json:"-"` // $battery, $time and the other system variables
+}
+
+type SceneConfig struct {
+	Name     string    `json:"name"`
+	Elements []Element `json:"elements"`
+	Modal    bool      `json:"modal"` // Pushed over the active scene, which stays visible
+
+	OnEnter []Action     `json:"onEnter"` // Run when the scene becomes active
+	OnExit  []Action     `json:"onExit"`  // Run before another scene replaces it
+	OnTimer []SceneTimer `json:"onTimer"` // Run periodically while it is active
+}
+
+// Action is one trigger with its parameters, spelled like the trigger
+// fields of an element. An action with If is a branch: its own trigger and
+// Then run if the condition holds, Else otherwise.
+type Action struct {
+	Trigger           string `json:"trigger"`
+	TriggerTarget     string `json:"triggerTarget"`
+	TriggerValue      string `json:"triggerValue"`
+	ExternalAppReturn string `json:"externalAppReturn"`
+	Persist           bool   `json:"persist"` // Keep the variable this action writes across launches
+
+	// external_app only: leave the app running beside the player instead
+	// of handing it the screen, what to do once it exits, and the command
+	// line (see appCommand).
+	Background bool              `json:"background"`
+	OnReturn   []Action          `json:"onReturn"`
+	Args       []string          `json:"args"`
+	Cwd        string            `json:"cwd"`
+	Env        map[string]string `json:"env"`
+	Shell      bool              `json:"shell"`
+	Job        string            `json:"job"`     // Name of the variable holding the app's state; implies background
+	Timeout    int               `json:"timeout"` // Milliseconds before a background app is killed, 0 for never
+
+	If   string   `json:"if"`
+	Then []Action `json:"then"`
+	Else []Action `json:"else"`
+}
+
+// SceneTimer runs Actions every Interval milliseconds.
+type SceneTimer struct {
+	Interval int      `json:"interval"`
+	Actions  []Action `json:"actions"`
+}
+
+// apply returns element with its trigger replaced by the action's, so the
+// action runs like any element trigger.
+func (a Action) apply(element Element) Element {
+	element.Trigger = a.Trigger
+	element.TriggerTarget = a.TriggerTarget
+	element.TriggerValue = a.TriggerValue
+	element.ExternalAppReturn = a.ExternalAppReturn
+	element.Background = a.Background
+	element.OnReturn = a.OnReturn
+	element.Args = a.Args
+	element.Cwd = a.Cwd
+	element.Env = a.Env
+	element.Shell = a.Shell
+	element.Job = a.Job
+	element.Timeout = a.Timeout
+	return element
+}
+
+type Element struct {
+	Type          string      `json:"type"`
+	Text          string      `json:"text"`
+	Color         string      `json:"color"`
+	X             int32       `json:"x"`
+	Y             int32       `json:"y"`
+	Font          string      `json:"font"`
+	BgColor       string      `json:"bgColor"`
+	Trigger       string      `json:"trigger"`
+	TriggerTarget string      `json:"triggerTarget"`
+	TriggerValue  string      `json:"triggerValue"`
+	Image         string      `json:"image"`
+	Width         StringOrInt `json:"width"`
+	Height        StringOrInt `json:"height"`
+	Video         string      `json:"video"`
+	Variable      string      `json:"variable"`
+	Command       string      `json:"command"`      // For collapsed and dynamic list execution
+	ListVariable  string      `json:"listVariable"` // For storing list data
+	Opacity       *float64    `json:"opacity"`      // 0 to 1, nil means opaque
+	Actions       []Action    `json:"actions"`      // Run in order instead of Trigger
+	VisibleIf     string      `json:"visibleIf"`    // Condition for drawing the element at all
+	EnabledIf     string      `json:"enabledIf"`    // Condition for focusing and activating it
+	Persist       bool        `json:"persist"`      // Keep the variable this element writes across launches
+	Background    bool        `json:"background"`   // external_app: keep drawing while the app runs
+	OnReturn      []Action    `json:"onReturn"`     // external_app: run once the app exits
+
+	// external_app's command line, see appCommand.
+	Args  []string          `json:"args"`
+	Cwd   string            `json:"cwd"`
+	Env   map[string]string `json:"env"`
+	Shell bool              `json:"shell"`
+
+	// external_app as a job, see startApp.
+	Job     string `json:"job"`
+	Timeout int    `json:"timeout"`
+
+	// Fields written by the web generator. normalizeElement folds them
+	// into the trigger fields above.
+	SceneChange         string `json:"sceneChange"`
+	ExternalAppPath     string `json:"externalAppPath"`
+	ExternalAppReturn   string `json:"externalAppReturn"` // Variable that receives the app's exit code
+	VariableChange      string `json:"variableChange"`
+	VariableChangeValue string `json:"variableChangeValue"`
+	MediaVariable       string `json:"mediaVariable"` // Variable holding the play_video/play_image path
+	VideoVariable       string `json:"videoVariable"` // Variable holding a video element's path
+}
+
+// normalizeElement maps the field names the web generator exports onto the
+// ones the player uses, so both spellings of a config behave the same.
+func normalizeElement(element *Element) {
+	switch element.Trigger {
+	case "change_scene":
+		if element.TriggerTarget == "" {
+			element.TriggerTarget = element.SceneChange
+		}
+	case "external_app":
+		if element.TriggerTarget == "" {
+			element.TriggerTarget = element.ExternalAppPath
+		}
+	case "set_variable":
+		if element.TriggerTarget == "" {
+			element.TriggerTarget = element.VariableChange
+		}
+		if element.TriggerValue == "" {
+			element.TriggerValue = element.VariableChangeValue
+		}
+	case "play_video", "play_image":
+		if element.TriggerTarget == "" && element.MediaVariable != "" {
+			element.TriggerTarget = "$" + element.MediaVariable
+		}
+	}
+	if element.Video == "" && element.VideoVariable != "" {
+		element.Video = "$" + element.VideoVariable
+	}
+}
+
+// DynamicListItem is one entry of a dynamiclist. Commands print a JSON array
+// of these; Name is shown and Value is stored in the element's variable.
+type DynamicListItem struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type CollapsedListItem struct {
+	Title       string `json:"title"`
+	Header      string `json:"header"`
+	Description string `json:"description"`
+	Image       string `json:"image"`
+}
+
+// Add this UnmarshalJSON method for StringOrInt
+func (s *StringOrInt) UnmarshalJSON(data []byte) error {
+	// Try string first
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		*s = StringOrInt(str)
+		return nil
+	}
+
+	// Then try number
+	var num int
+	if err := json.Unmarshal(data, &num); err == nil {
+		*s = StringOrInt(strconv.Itoa(num))
+		return nil
+	}
+
+	// Handle null values
+	if string(data) == "null" {
+		*s = ""
+		return nil
+	}
+
+	return fmt.Errorf("StringOrInt: expected string or integer, got %q", data)
+}
+
+func (v *Variables) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	// Handle known fields explicitly
+	knownFields := map[string]bool{
+		"buttonColor":     true,
+		"labelColor":      true,
+		"backgroundImage": true,
+		"fonts":           true,
+		"fontSizes":       true,
+	}
+
+	v.Custom = make(map[string]interface{})
+
+	for key, val := range raw {
+		if knownFields[key] {
+			switch key {
+			case "buttonColor":
+				if err := json.Unmarshal(val, &v.ButtonColor); err != nil {
+					return err
+				}
+			case "labelColor":
+				if err := json.Unmarshal(val, &v.LabelColor); err != nil {
+					return err
+				}
+			case "backgroundImage":
+				if err := json.Unmarshal(val, &v.BackgroundImage); err != nil {
+					return err
+				}
+			case "fonts":
+				if err := json.Unmarshal(val, &v.Fonts); err != nil {
+					return err
+				}
+			case "fontSizes":
+				if err := json.Unmarshal(val, &v.FontSizes); err != nil {
+					return err
+				}
+			}
+		} else {
+			var value interface{}
+			if err := json.Unmarshal(val, &value); err != nil {
+				return err
+			}
+			v.Custom[key] = value
+			configLog.Debug("stored custom variable", "name", key, "value", value)
+		}
+	}
+	return nil
+}
+
+// Get looks a variable up case-insensitively: custom variables first, then
+// the predefined colors, background, fonts and font sizes.
+func (v *Variables) Get(name string) string {
+	if value, ok := v.Lookup(name); ok {
+		return value
+	}
+	warnLimited(configLog, strings.ToLower(name), "missing variable", "name", name)
+	return "MISSING_VAR"
+}
+
+// Lookup is Get without the warning. The second result reports whether the
+// variable exists. name may be a dotted path into a list or object, like
+// "user.name" or "items.0.title"; lists and objects come back as JSON.
+func (v *Variables) Lookup(name string) (string, bool) {
+	value, ok := v.Value(name)
+	if !ok {
+		return "", false
+	}
+	return formatValue(value), true
+}
+
+// Value is Lookup without the conversion to text: custom variables keep the
+// type they were decoded or set with.
+func (v *Variables) Value(name string) (interface{}, bool) {
+	if value, ok := v.rootValue(name); ok {
+		return value, true
+	}
+	root, path, dotted := strings.Cut(name, ".")
+	if !dotted {
+		return nil, false
+	}
+	value, ok := v.rootValue(root)
+	if !ok {
+		return nil, false
+	}
+	return lookupPath(value, strings.Split(path, "."))
+}
+
+func (v *Variables) rootValue(name string) (interface{}, bool) {
+	targetKey := strings.ToLower(name)
+
+	// Check custom variables first
+	for key, val := range v.Custom {
+		if strings.EqualFold(key, targetKey) {
+			return val, true
+		}
+	}
+
+	// System variables
+	for key, val := range v.System {
+		if strings.EqualFold(key, targetKey) {
+			return val, true
+		}
+	}
+
+	// Predefined variables
+	switch targetKey {
+	case "buttoncolor":
+		return fmt.Sprintf("%d,%d,%d", v.ButtonColor.R, v.ButtonColor.G, v.ButtonColor.B), true
+	case "labelcolor":
+		return fmt.Sprintf("%d,%d,%d", v.LabelColor.R, v.LabelColor.G, v.LabelColor.B), true
+	case "backgroundimage":
+		return v.BackgroundImage, true
+	}
+
+	// Fonts
+	for key, path := range v.Fonts {
+		if strings.EqualFold(key, targetKey) {
+			return path, true
+		}
+	}
+
+	// Font sizes
+	for key, size := range v.FontSizes {
+		if strings.EqualFold(key, targetKey) {
+			return size, true
+		}
+	}
+
+	return nil, false
+}
+
+// parseHexColor parses "#rrggbb", "rrggbb" or the short "#rgb" form.
+func parseHexColor(hex string) (uint8, uint8, uint8, bool) {
+	hex = strings.TrimPrefix(hex, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return 0, 0, 0, false
+	}
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	return uint8(value >> 16), uint8(value >> 8), uint8(value), true
+}
+
+// LoadConfig reads and decodes a jukaconfig.json file.
+func LoadConfig(filename string) (*Config, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var config Config
+	decoder := json.NewDecoder(file)
+	err = decoder.Decode(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ensure fonts exist
+	if config.Variables.Fonts == nil {
+		config.Variables.Fonts = make(map[string]string)
+	}
+	if config.Variables.FontSizes == nil {
+		config.Variables.FontSizes = make(map[string]int)
+	}
+	if config.Variables.Custom == nil {
+		config.Variables.Custom = make(map[string]interface{})
+	}
+
+	for i := range config.Scenes {
+		for j := range config.Scenes[i].Elements {
+			normalizeElement(&config.Scenes[i].Elements[j])
+		}
+	}
+
+	return &config, nil
+}
+
+func setConfigVariable(config *Config, variableName, value string) {
+	//fmt.Println("Setting configuration variable:", variableName, "to", value)
+	switch variableName {
+	case "buttonColor":
+		colorParts := strings.Split(value, ",")
+		if len(colorParts) == 3 {
+			r, _ := strconv.Atoi(colorParts[0])
+			g, _ := strconv.Atoi(colorParts[1])
+			b, _ := strconv.Atoi(colorParts[2])
+			config.Variables.ButtonColor = struct {
+				R int `json:"r"`
+				G int `json:"g"`
+				B int `json:"b"`
+			}{r, g, b}
+		}
+	case "labelColor":
+		colorParts := strings.Split(value, ",")
+		if len(colorParts) == 3 {
+			r, _ := strconv.Atoi(colorParts[0])
+			g, _ := strconv.Atoi(colorParts[1])
+			b, _ := strconv.Atoi(colorParts[2])
+			config.Variables.LabelColor = struct {
+				R int `json:"r"`
+				G int `json:"g"`
+				B int `json:"b"`
+			}{r, g, b}
+		}
+	case "backgroundImage":
+		config.Variables.BackgroundImage = value
+	}
+}
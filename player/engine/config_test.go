@@ -0,0 +1,108 @@
+package engine
+
+import "testing"
+
+func TestNormalizeElement(t *testing.T) {
+	tests := []struct {
+		name    string
+		element Element
+		want    Element // Only the trigger fields and Video are compared
+	}{
+		{
+			"sceneChange",
+			Element{Trigger: "change_scene", SceneChange: "Scene 2"},
+			Element{TriggerTarget: "Scene 2"},
+		},
+		{
+			"triggerTarget wins over sceneChange",
+			Element{Trigger: "change_scene", TriggerTarget: "Home", SceneChange: "Scene 2"},
+			Element{TriggerTarget: "Home"},
+		},
+		{
+			"externalAppPath",
+			Element{Trigger: "external_app", ExternalAppPath: "/usr/bin/retroarch"},
+			Element{TriggerTarget: "/usr/bin/retroarch"},
+		},
+		{
+			"variableChange",
+			Element{Trigger: "set_variable", VariableChange: "theme", VariableChangeValue: "dark"},
+			Element{TriggerTarget: "theme", TriggerValue: "dark"},
+		},
+		{
+			"mediaVariable for play_video",
+			Element{Trigger: "play_video", MediaVariable: "intro"},
+			Element{TriggerTarget: "$intro"},
+		},
+		{
+			"mediaVariable for play_image",
+			Element{Trigger: "play_image", MediaVariable: "splash"},
+			Element{TriggerTarget: "$splash"},
+		},
+		{
+			"mediaVariable ignored for other triggers",
+			Element{Trigger: "change_scene", MediaVariable: "intro"},
+			Element{},
+		},
+		{
+			"videoVariable",
+			Element{Type: "video", VideoVariable: "intro"},
+			Element{Video: "$intro"},
+		},
+		{
+			"video wins over videoVariable",
+			Element{Type: "video", Video: "intro.mp4", VideoVariable: "intro"},
+			Element{Video: "intro.mp4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			element := tt.element
+			normalizeElement(&element)
+			if element.TriggerTarget != tt.want.TriggerTarget || element.TriggerValue != tt.want.TriggerValue || element.Video != tt.want.Video {
+				t.Errorf("got target %q, value %q, video %q; want %q, %q, %q",
+					element.TriggerTarget, element.TriggerValue, element.Video,
+					tt.want.TriggerTarget, tt.want.TriggerValue, tt.want.Video)
+			}
+		})
+	}
+}
+
+// TestGeneratorExport checks that a config exported from the web generator,
+// using every field it writes, loads, validates and maps onto the fields
+// the player uses.
+func TestGeneratorExport(t *testing.T) {
+	const dir = "../testdata"
+	config, err := LoadConfig(dir + "/generator-export.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range Validate(config, dir) {
+		if p.Severity == SeverityError {
+			t.Error(p)
+		}
+	}
+
+	elements := config.Scenes[0].Elements
+	checks := []struct {
+		index int
+		field string
+		got   string
+		want  string
+	}{
+		{2, "triggerTarget", elements[2].TriggerTarget, "Scene 2"},
+		{3, "triggerTarget", elements[3].TriggerTarget, "true"},
+		{3, "externalAppReturn", elements[3].ExternalAppReturn, "lastExitCode"},
+		{4, "triggerTarget", elements[4].TriggerTarget, "playerName"},
+		{4, "triggerValue", elements[4].TriggerValue, "Juka"},
+		{5, "triggerTarget", elements[5].TriggerTarget, "$introVideo"},
+		{6, "triggerTarget", elements[6].TriggerTarget, "$splash"},
+		{8, "video", elements[8].Video, "$introVideo"},
+		{10, "command", elements[10].Command, `printf '[{"name":"Dark","value":"dark"},{"name":"Light","value":"light"}]'`},
+		{10, "variable", elements[10].Variable, "theme"},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("element %d: %s is %q, want %q", c.index, c.field, c.got, c.want)
+		}
+	}
+}
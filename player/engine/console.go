@@ -0,0 +1,232 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"github.com/veandco/go-sdl2/ttf"
+)
+
+// consolePadding is the space between a console's edge and its text.
+const consolePadding = 8
+
+// consoleScrollRows is how far one up or down press scrolls a console.
+const consoleScrollRows = 3
+
+// console is the runtime state of a console element: the output of its
+// command so far and how it is scrolled.
+type console struct {
+	term    *termBuffer
+	top     int  // First row shown while not following
+	follow  bool // Keep the newest output in view
+	running bool
+	cancel  context.CancelFunc
+}
+
+// consoleWriter hands output from the process goroutines to the main loop
+// a batch at a time, however often the program writes.
+type consoleWriter struct {
+	e       *Engine
+	console *console
+
+	mu     sync.Mutex
+	buf    []byte
+	queued bool
+}
+
+func (w *consoleWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) < maxCapture {
+		w.buf = append(w.buf, p...)
+	}
+	if !w.queued {
+		w.queued = true
+		w.e.post(w.flush)
+	}
+	return len(p), nil
+}
+
+func (w *consoleWriter) flush() {
+	w.mu.Lock()
+	data := w.buf
+	w.buf, w.queued = nil, false
+	w.mu.Unlock()
+	w.console.term.write(data)
+}
+
+// consoleFor returns the state of the console at index in the active
+// scene, starting its command the first time it is needed. The command is
+// a job like an external_app's: it can have a job name and a timeout.
+func (e *Engine) consoleFor(index int, element Element) *console {
+	key := fmt.Sprintf("%d/%d", e.currentSceneIndex, index)
+	if c, ok := e.consoles[key]; ok {
+		return c
+	}
+
+	c := &console{term: newTermBuffer(), follow: true}
+	e.consoles[key] = c
+	if element.Command == "" {
+		return c
+	}
+	if e.noCommands {
+		c.term.write([]byte("\x1b[90m[commands are disabled]\x1b[0m\n"))
+		return c
+	}
+	if e.jobRunning(element.Job) {
+		c.term.write([]byte("\x1b[90m[job " + element.Job + " is already running]\x1b[0m\n"))
+		return c
+	}
+
+	ctx, cancel := jobContext(element.Timeout)
+	// Values are quoted, so they can't inject shell syntax.
+	command := substituteVariablesWith(element.Command, e.config, shellQuote)
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	w := &consoleWriter{e: e, console: c}
+	cmd.Stdout, cmd.Stderr = w, w
+
+	c.running, c.cancel = true, cancel
+	e.startJob(element.Job, ctx, cancel, cmd, func(result processResult, reason string) {
+		c.running = false
+		if reason == "" && result.exitCode != 0 {
+			reason = "exit code " + strconv.Itoa(result.exitCode)
+		}
+		if reason != "" {
+			if len(c.term.current) > 0 {
+				c.term.newline()
+			}
+			c.term.write([]byte("\x1b[0;90m[" + reason + "]\x1b[0m\n"))
+		}
+	})
+	return c
+}
+
+// stopConsoles kills the commands of all consoles and forgets their output.
+func (e *Engine) stopConsoles() {
+	for _, c := range e.consoles {
+		if c.running {
+			c.cancel()
+		}
+	}
+	e.consoles = make(map[string]*console)
+}
+
+// handleConsoleAction scrolls the focused console: up and down move
+// through the output, confirm turns following new output on and off. It
+// returns false if no console is focused or the action isn't one of these,
+// leaving left and right to move the focus.
+func (e *Engine) handleConsoleAction(action InputAction) bool {
+	elements := e.Scene().Elements
+	index := e.selectedButtonIndex
+	if index < 0 || index >= len(elements) || elements[index].Type != "console" {
+		return false
+	}
+	c := e.consoleFor(index, elements[index])
+	switch action {
+	case InputUp:
+		c.follow = false
+		c.top -= consoleScrollRows
+	case InputDown:
+		c.top += consoleScrollRows
+	case InputConfirm:
+		c.follow = !c.follow
+	default:
+		return false
+	}
+	return true
+}
+
+func (e *Engine) renderConsole(index int, element Element, focused bool) {
+	config := e.config
+	c := e.consoleFor(index, element)
+
+	width, height := consoleSize(element, config)
+	color := resolveColor(config, element.Color, sdl.Color{R: 204, G: 204, B: 204, A: 255})
+	bgColor := resolveColor(config, element.BgColor, sdl.Color{R: 0, G: 0, B: 0, A: 255})
+	e.setDrawColor(bgColor)
+	e.renderer.FillRect(&sdl.Rect{X: element.X, Y: element.Y, W: width, H: height})
+	if focused {
+		e.setDrawColor(color)
+		e.renderer.DrawRect(&sdl.Rect{X: element.X, Y: element.Y, W: width, H: height})
+	}
+
+	fontName := orDefault(element.Font, "console")
+	font, _ := e.getFontAndSize(fontName)
+	if font == nil {
+		return
+	}
+	if !font.FaceIsFixedWidth() {
+		warnLimited(renderLog, "console font:"+strings.ToLower(fontName), "console font is not monospaced, so columns won't line up", "font", fontName)
+	}
+	cellWidth, lineHeight := getTextDimensions(font, "M")
+	if cellWidth <= 0 || lineHeight <= 0 {
+		return
+	}
+	columns := int((width - 2*consolePadding) / cellWidth)
+	visible := max(int((height-2*consolePadding)/lineHeight), 1)
+
+	var rows [][]termSpan
+	for _, line := range c.term.allLines() {
+		rows = append(rows, wrapLine(line, columns)...)
+	}
+	last := max(len(rows)-visible, 0)
+	if c.follow {
+		c.top = last
+	}
+	c.top = min(max(c.top, 0), last)
+
+	y := element.Y + consolePadding
+	for _, row := range rows[c.top:min(c.top+visible, len(rows))] {
+		x := element.X + consolePadding
+		for _, span := range row {
+			spanColor := color
+			if span.color.Set {
+				spanColor = sdl.Color{R: span.color.R, G: span.color.G, B: span.color.B, A: 255}
+			}
+			x += e.renderPlainText(font, string(span.text), spanColor, x, y)
+		}
+		y += lineHeight
+	}
+
+	// Scroll bar
+	if len(rows) > visible {
+		trackHeight := height - 2*consolePadding
+		barHeight := max(trackHeight*int32(visible)/int32(len(rows)), 8)
+		barY := element.Y + consolePadding + (trackHeight-barHeight)*int32(c.top)/int32(last)
+		e.setDrawColor(sdl.Color{R: color.R, G: color.G, B: color.B, A: 128})
+		e.renderer.FillRect(&sdl.Rect{X: element.X + width - 6, Y: barY, W: 3, H: barHeight})
+	}
+}
+
+// renderPlainText draws text as it is, without substituting variables, and
+// returns its width.
+func (e *Engine) renderPlainText(font *ttf.Font, text string, color sdl.Color, x, y int32) int32 {
+	if text == "" {
+		return 0
+	}
+	texture, w, h, err := e.resources.Text(font, text, color)
+	if err != nil {
+		warnLimited(renderLog, "console text", "cannot render text", "err", err)
+		return 0
+	}
+	e.copyTexture(texture, &sdl.Rect{X: x, Y: y, W: w, H: h})
+	return w
+}
+
+// consoleSize returns a console's size, 600×300 unless it sets one.
+func consoleSize(element Element, config *Config) (int32, int32) {
+	width, _ := strconv.Atoi(substituteVariables(string(element.Width), config))
+	height, _ := strconv.Atoi(substituteVariables(string(element.Height), config))
+	if width <= 0 {
+		width = 600
+	}
+	if height <= 0 {
+		height = 300
+	}
+	return int32(width), int32(height)
+}
@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// imageMagic lists the leading bytes of the image formats SDL_image reads.
+// They tell an inline base64 payload apart from a file name.
+var imageMagic = [][]byte{
+	[]byte("\x89PNG"),
+	[]byte("\xff\xd8\xff"), // JPEG
+	[]byte("GIF8"),
+	[]byte("BM"),
+	[]byte("RIFF"), // WebP
+	[]byte("II*\x00"),
+	[]byte("MM\x00*"), // TIFF
+}
+
+// decodeInlineImage decodes images embedded in a config: data: URLs as the
+// web generator exports them for uploaded files, and bare base64 payloads.
+// ok is false if s looks like a file path; err is set if it is inline data
+// that cannot be decoded.
+func decodeInlineImage(s string) (data []byte, ok bool, err error) {
+	if strings.HasPrefix(s, "data:") {
+		header, payload, found := strings.Cut(s[len("data:"):], ",")
+		if !found {
+			return nil, true, errors.New("data URL has no comma")
+		}
+		if strings.HasSuffix(header, ";base64") {
+			data, err = decodeBase64(payload)
+			return data, true, err
+		}
+		text, err := url.PathUnescape(payload)
+		return []byte(text), true, err
+	}
+
+	// Bare base64 is only accepted if it decodes to a known image format;
+	// anything else is treated as a path. File names have an extension,
+	// and a dot is never part of base64.
+	if len(s) < 16 || strings.Contains(s, ".") {
+		return nil, false, nil
+	}
+	data, err = decodeBase64(s)
+	if err != nil {
+		return nil, false, nil
+	}
+	for _, magic := range imageMagic {
+		if bytes.HasPrefix(data, magic) {
+			return data, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// decodeBase64 accepts standard and URL-safe alphabets, with or without
+// padding, and ignores whitespace from wrapped lines.
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\n', '\r', '\t':
+			return -1
+		}
+		return r
+	}, s)
+	s = strings.TrimRight(s, "=")
+	if strings.ContainsAny(s, "-_") {
+		return base64.RawURLEncoding.DecodeString(s)
+	}
+	return base64.RawStdEncoding.DecodeString(s)
+}
@@ -0,0 +1,132 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// dynamicList is the runtime state of a dynamiclist element: the items its
+// command produced and which one is picked.
+type dynamicList struct {
+	items    []DynamicListItem
+	selected int
+	loading  bool // The command is still running
+}
+
+// parseDynamicList parses a dynamiclist command's output. A JSON array of
+// {"name", "value"} objects is preferred; otherwise every non-empty line
+// becomes an item whose name and value are the line itself.
+func parseDynamicList(output []byte) []DynamicListItem {
+	var items []DynamicListItem
+	if err := json.Unmarshal(output, &items); err == nil {
+		return items
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			items = append(items, DynamicListItem{Name: line, Value: line})
+		}
+	}
+	return items
+}
+
+// dynamicListFor returns the state of the dynamiclist at index in the active
+// scene. The first time it is needed its command starts in the background;
+// the list stays empty until the command is done, or for good if commands
+// are disabled.
+func (e *Engine) dynamicListFor(index int, element Element) *dynamicList {
+	key := fmt.Sprintf("%d/%d", e.currentSceneIndex, index)
+	if list, ok := e.dynamicLists[key]; ok {
+		return list
+	}
+
+	list := &dynamicList{}
+	e.dynamicLists[key] = list
+	if element.Command == "" || e.noCommands {
+		return list
+	}
+
+	list.loading = true
+	command := substituteVariablesWith(element.Command, e.config, shellQuote)
+	ctx, cancel := context.WithTimeout(context.Background(), listCommandTimeout)
+	e.startProcess(exec.CommandContext(ctx, "sh", "-c", command), func(result processResult) {
+		cancel()
+		list.loading = false
+		if result.err != nil {
+			processLog.Error("dynamic list command failed", "command", command, "err", result.err)
+			return
+		}
+		e.fillDynamicList(list, element, parseDynamicList(result.stdout))
+	})
+	return list
+}
+
+// fillDynamicList gives list its items, starting on the first item matching
+// the element's variable or publishing the first one.
+func (e *Engine) fillDynamicList(list *dynamicList, element Element, items []DynamicListItem) {
+	list.items = items
+	if element.Variable == "" || len(items) == 0 {
+		return
+	}
+	if current, ok := e.config.Variables.Lookup(element.Variable); ok {
+		for i, item := range items {
+			if item.Value == current {
+				list.selected = i
+				break
+			}
+		}
+	} else {
+		e.setVariable(element.Variable, items[0].Value)
+	}
+}
+
+// cycleDynamicList picks the next item of a dynamiclist and stores its value
+// in the element's variable.
+func (e *Engine) cycleDynamicList(index int, element Element) {
+	list := e.dynamicListFor(index, element)
+	if len(list.items) == 0 {
+		return
+	}
+	list.selected = (list.selected + 1) % len(list.items)
+	if element.Variable != "" {
+		e.setVariable(element.Variable, list.items[list.selected].Value)
+	}
+}
+
+func (e *Engine) renderDynamicList(index int, element Element, color, bgColor sdl.Color) {
+	width, height := dynamicListSize(element, e.config)
+	e.setDrawColor(bgColor)
+	e.renderer.FillRect(&sdl.Rect{X: element.X, Y: element.Y, W: width, H: height})
+
+	font, _ := e.getFontAndSize(element.Font)
+	label := "Dynamic List"
+	if list := e.dynamicListFor(index, element); len(list.items) > 0 {
+		label = "< " + list.items[list.selected].Name + " >"
+	} else if list.loading {
+		label = "Loading..."
+	}
+	textWidth, textHeight := getTextDimensions(font, label)
+	e.renderText(font, label, color,
+		element.X+(width-textWidth)/2,
+		element.Y+(height-textHeight)/2)
+}
+
+// dynamicListSize matches the generator's default dynamiclist box.
+func dynamicListSize(element Element, config *Config) (int32, int32) {
+	width, _ := strconv.Atoi(substituteVariables(string(element.Width), config))
+	height, _ := strconv.Atoi(substituteVariables(string(element.Height), config))
+	if width <= 0 {
+		width = 600
+	}
+	if height <= 0 {
+		height = 40
+	}
+	return int32(width), int32(height)
+}
@@ -0,0 +1,40 @@
+package engine
+
+import "testing"
+
+func TestFillDynamicList(t *testing.T) {
+	items := []DynamicListItem{
+		{Name: "Dark", Value: "dark"},
+		{Name: "Dark (again)", Value: "dark"},
+		{Name: "Light", Value: "light"},
+	}
+	tests := []struct {
+		name     string
+		variable string
+		value    interface{} // Of the variable before; nil if it is missing
+		selected int
+		want     string // The variable after
+	}{
+		{"first duplicate wins", "theme", "dark", 0, "dark"},
+		{"match", "theme", "light", 2, "light"},
+		{"no match", "theme", "blue", 0, "blue"},
+		{"missing variable", "theme", nil, 0, "dark"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Variables: Variables{Custom: map[string]interface{}{}}}
+			if tt.value != nil {
+				config.Variables.Custom[tt.variable] = tt.value
+			}
+			e := New(config)
+			list := &dynamicList{}
+			e.fillDynamicList(list, Element{Variable: tt.variable}, items)
+			if list.selected != tt.selected {
+				t.Errorf("selected %d, want %d", list.selected, tt.selected)
+			}
+			if got, _ := config.Variables.Lookup(tt.variable); got != tt.want {
+				t.Errorf("%s is %q, want %q", tt.variable, got, tt.want)
+			}
+		})
+	}
+}
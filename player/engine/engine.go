@@ -0,0 +1,574 @@
+// Package engine runs JukaGUI apps described by a jukaconfig.json file.
+//
+// The player binary is a thin wrapper around this package; other Go programs
+// can embed it the same way:
+//
+//	if err := engine.Init(); err != nil { ... }
+//	defer engine.Quit()
+//	config, err := engine.LoadConfig("jukaconfig.json")
+//	e := engine.New(config)
+//	if err := e.Open(); err != nil { ... }
+//	defer e.Close()
+//	e.Run()
+package engine
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/veandco/go-sdl2/img"
+	"github.com/veandco/go-sdl2/sdl"
+	"github.com/veandco/go-sdl2/ttf"
+)
+
+// SceneChangeFunc is called after the active scene changes.
+type SceneChangeFunc func(e *Engine, from, to int)
+
+// TriggerFunc handles a trigger name the player does not know about.
+type TriggerFunc func(e *Engine, element Element)
+
+// EventFunc sees every SDL event before the engine does. Returning true
+// marks the event as handled and the engine skips it.
+type EventFunc func(e *Engine, event sdl.Event) bool
+
+// FrameFunc runs after the scene has been drawn, before it is presented.
+type FrameFunc func(e *Engine)
+
+// Engine holds a loaded config, its window and all of the player's runtime
+// state. The zero value is not usable; create one with New.
+type Engine struct {
+	config     *Config
+	backend    Backend
+	renderer   *sdl.Renderer
+	resources  *Resources
+	controller *sdl.GameController
+	headless   bool
+	noCommands bool // Set by SetCommandsEnabled(false)
+	running    bool
+	started    bool // The first scene's onEnter actions have run
+	frames     frameScheduler
+
+	fullscreen   bool
+	windowWidth  int32 // Zero picks the size from the config's resolution
+	windowHeight int32
+
+	currentSceneIndex   int
+	selectedButtonIndex int
+	menuButtonRects     map[int]sdl.Rect // Scene index → hitbox
+	videoPlayed         bool             // Track if video has been played
+
+	inputActiveElement    *Element // Currently active input element
+	inputTextBuffer       string   // Buffer for text input
+	inputText             string
+	keyboard              [][]string
+	keyboardPosX          int
+	keyboardPosY          int
+	virtualKeyboardActive bool
+
+	sceneStack     []sceneEntry // Scenes push_scene left, most recent last
+	sceneTimers    []time.Time  // Next run of each of the active scene's timers
+	timerRuns      []*actionRun // Latest run of each timer's actions
+	leavingScene   bool         // onExit actions are running
+	sceneRedirects int          // Nesting depth of scene changes made by onEnter actions
+
+	conditions   map[string]expr         // Parsed visibleIf, enabledIf and if conditions
+	dynamicLists map[string]*dynamicList // Keyed by scene and element index
+	listLoads    map[string]bool         // Lower-cased collapsedlist variables whose command was started
+	jobs         map[string]*job         // Keyed by lower-cased job name
+	consoles     map[string]*console     // Keyed by scene and element index
+	alpha        uint8                   // Opacity of the element being drawn
+
+	fileCustom   map[string]interface{} // Custom variables as loaded, to spot runtime changes
+	reloadErrors []string               // Why the last reload failed, shown over the scene
+	watchStop    chan struct{}
+
+	bindings *bindings // Keys and buttons → input actions
+
+	policy       *Policy   // The player's policy, on top of the config's
+	approval     *approval // Set while the user is asked to allow the app's commands
+	approvalPath string    // File remembering the commands the user allowed
+
+	systemInfo SystemInfo // Nil reads the system as the config says
+	systemStop chan struct{}
+
+	persistent map[string]bool // Lower-cased names of variables kept across launches
+	statePath  string          // File persistent variables are saved to
+	stateDirty bool
+	saveTimer  *time.Timer
+
+	// Work handed to the main loop by other goroutines.
+	pendingMu sync.Mutex
+	pending   []func()
+
+	sceneChangeHooks []SceneChangeFunc
+	triggers         map[string]TriggerFunc
+	eventHooks       []EventFunc
+	frameHooks       []FrameFunc
+}
+
+// Init initializes the SDL, SDL_ttf and SDL_image subsystems the engine
+// needs. Programs that already initialize SDL themselves can skip it.
+func Init() error {
+	if err := sdl.Init(sdl.INIT_VIDEO | sdl.INIT_JOYSTICK | sdl.INIT_GAMECONTROLLER); err != nil {
+		return fmt.Errorf("initializing SDL: %w", err)
+	}
+	return initLibraries()
+}
+
+// InitHeadless is Init for machines without a display. It selects SDL's
+// dummy video driver and skips input devices; only OffscreenBackend can be
+// used afterwards.
+func InitHeadless() error {
+	sdl.SetHint(sdl.HINT_VIDEODRIVER, "dummy")
+	if os.Getenv("SDL_VIDEODRIVER") == "" {
+		os.Setenv("SDL_VIDEODRIVER", "dummy") // SDL before 2.0.22 ignores the hint
+	}
+	if err := sdl.Init(sdl.INIT_VIDEO); err != nil {
+		return fmt.Errorf("initializing SDL: %w", err)
+	}
+	return initLibraries()
+}
+
+func initLibraries() error {
+	if err := ttf.Init(); err != nil {
+		return fmt.Errorf("initializing TTF: %w", err)
+	}
+	if err := img.Init(img.INIT_PNG | img.INIT_JPG | img.INIT_TIF); err != nil {
+		return fmt.Errorf("initializing IMG: %w", err)
+	}
+	return nil
+}
+
+// Quit shuts down everything started by Init.
+func Quit() {
+	img.Quit()
+	ttf.Quit()
+	sdl.Quit()
+}
+
+// New creates an engine for config. Call Open or OpenBackend before Step or
+// Run.
+func New(config *Config) *Engine {
+	e := &Engine{
+		config:          config,
+		menuButtonRects: make(map[int]sdl.Rect),
+		conditions:      make(map[string]expr),
+		dynamicLists:    make(map[string]*dynamicList),
+		listLoads:       make(map[string]bool),
+		jobs:            make(map[string]*job),
+		consoles:        make(map[string]*console),
+		alpha:           255,
+		fileCustom:      copyCustom(config.Variables.Custom),
+		persistent:      persistentVariables(config),
+		triggers:        make(map[string]TriggerFunc),
+	}
+	e.frames.init()
+	e.initKeyboard()
+	e.useBindings()
+
+	// Auto-select the first selectable element in the initial scene
+	if len(config.Scenes) > 0 {
+		if firstSelectable := e.findFirstSelectableElement(config.Scenes[0]); firstSelectable != -1 {
+			e.selectedButtonIndex = firstSelectable
+		}
+	}
+	return e
+}
+
+// Open creates the window and renderer and opens the first game controller.
+func (e *Engine) Open() error {
+	screenWidth, screenHeight := e.windowWidth, e.windowHeight
+	if screenWidth <= 0 || screenHeight <= 0 {
+		screenWidth, screenHeight = windowSize(e.config.LogicalSize())
+	}
+
+	backend, err := newWindowBackend(e.config.Title, screenWidth, screenHeight, e.fullscreen, e.frames.vsync)
+	if err != nil {
+		return err
+	}
+	e.OpenBackend(backend)
+
+	mapping1 := "030000005e0400008e02000014010000,X360 Controller,a:b0,b:b1,back:b6,dpdown:h0.4,dpleft:h0.8,dpright:h0.2,dpup:h0.1,guide:b8,leftshoulder:b4,leftstick:b9,lefttrigger:a2,leftx:a0,lefty:a1,rightshoulder:b5,rightstick:b10,righttrigger:a5,rightx:a3,righty:a4,start:b7,x:b2,y:b3,platform:Linux,"
+	mapping2 := "0000000058626f782047616d65706100,Xbox Gamepad (userspace driver),platform:Linux,a:b0,b:b1,x:b2,y:b3,start:b7,back:b6,guide:b8,dpup:h0.1,dpdown:h0.4,dpleft:h0.8,dpright:h0.2,leftshoulder:b4,rightshoulder:b5,lefttrigger:a5,righttrigger:a4,leftstick:b9,rightstick:b10,leftx:a0,lefty:a1,rightx:a2,righty:a3,"
+
+	if sdl.GameControllerAddMapping(mapping1) == -1 {
+		inputLog.Warn("cannot add controller mapping", "err", sdl.GetError())
+	}
+
+	if sdl.GameControllerAddMapping(mapping2) == -1 {
+		inputLog.Warn("cannot add controller mapping", "err", sdl.GetError())
+	}
+
+	if sdl.NumJoysticks() > 0 {
+		if controller := sdl.GameControllerOpen(0); controller != nil {
+			e.controller = controller
+			inputLog.Info("controller detected", "name", controller.Name())
+		}
+	}
+	return nil
+}
+
+// OpenBackend makes the engine draw into b instead of a window of its own.
+// Backends other than the built-in window are treated as headless: videos
+// are not started for them.
+func (e *Engine) OpenBackend(b Backend) {
+	e.backend = b
+	e.renderer = b.Renderer()
+	e.resources = newResources(e.renderer)
+	e.resources.allow = e.pathAllowed
+	e.renderer.SetDrawBlendMode(sdl.BLENDMODE_BLEND)
+	e.applyScaling()
+	_, isWindow := b.(*windowBackend)
+	e.headless = !isWindow
+	e.running = true
+}
+
+// Close saves persistent variables, stops watching the config and the
+// system, kills running jobs and releases the controller and the backend.
+func (e *Engine) Close() {
+	e.stopSystemInfo()
+	e.stopConsoles()
+	e.cancelJobs()
+	if e.saveTimer != nil {
+		e.saveTimer.Stop()
+	}
+	e.saveState()
+	if e.watchStop != nil {
+		close(e.watchStop)
+		e.watchStop = nil
+	}
+	if e.controller != nil {
+		e.controller.Close()
+		e.controller = nil
+	}
+	if e.resources != nil {
+		e.resources.Destroy()
+		e.resources = nil
+	}
+	if e.backend != nil {
+		e.backend.Destroy()
+		e.backend = nil
+		e.renderer = nil
+	}
+}
+
+// Config returns the config the engine is running.
+func (e *Engine) Config() *Config { return e.config }
+
+// Renderer returns the SDL renderer created by Open.
+func (e *Engine) Renderer() *sdl.Renderer { return e.renderer }
+
+// Window returns the SDL window created by Open, or nil when the engine
+// draws into another backend.
+func (e *Engine) Window() *sdl.Window {
+	if b, ok := e.backend.(*windowBackend); ok {
+		return b.window
+	}
+	return nil
+}
+
+// SceneIndex returns the index of the active scene in Config().Scenes.
+func (e *Engine) SceneIndex() int { return e.currentSceneIndex }
+
+// Scene returns the active scene.
+func (e *Engine) Scene() SceneConfig { return e.config.Scenes[e.currentSceneIndex] }
+
+// SelectedIndex returns the index of the focused element in the active
+// scene, or -1 if nothing is focused.
+func (e *Engine) SelectedIndex() int { return e.selectedButtonIndex }
+
+// ChangeScene switches to the scene with the given name and focuses its
+// first selectable element. It reports whether such a scene exists.
+func (e *Engine) ChangeScene(name string) bool {
+	for i, scene := range e.config.Scenes {
+		if scene.Name == name {
+			e.enterScene(i)
+			return true
+		}
+	}
+	return false
+}
+
+// PushScene switches to the named scene like ChangeScene, but remembers the
+// active one so PopScene can return to it. A modal scene opens over the
+// active scene instead of replacing it.
+func (e *Engine) PushScene(name string) bool {
+	for i, scene := range e.config.Scenes {
+		if scene.Name == name {
+			e.pushScene(i)
+			return true
+		}
+	}
+	return false
+}
+
+// PopScene returns to the scene PushScene left and focuses the element that
+// was focused there. It returns false if no scene was pushed.
+func (e *Engine) PopScene() bool {
+	return e.popScene()
+}
+
+// SetScene switches to the scene at index and focuses its first selectable
+// element. Before the first Step, no onEnter or onExit actions run.
+func (e *Engine) SetScene(index int) error {
+	if index < 0 || index >= len(e.config.Scenes) {
+		return fmt.Errorf("scene index %d out of range", index)
+	}
+	e.enterScene(index)
+	return nil
+}
+
+// Select focuses the element at index in the active scene. Pass -1 to clear
+// the focus.
+func (e *Engine) Select(index int) error {
+	if index < -1 || index >= len(e.Scene().Elements) {
+		return fmt.Errorf("element index %d out of range", index)
+	}
+	e.selectedButtonIndex = index
+	return nil
+}
+
+// OnSceneChange registers fn to run whenever the active scene changes.
+func (e *Engine) OnSceneChange(fn SceneChangeFunc) {
+	e.sceneChangeHooks = append(e.sceneChangeHooks, fn)
+}
+
+// RegisterTrigger makes name usable as an element trigger. Built-in
+// triggers cannot be overridden.
+func (e *Engine) RegisterTrigger(name string, fn TriggerFunc) {
+	e.triggers[name] = fn
+}
+
+// OnEvent registers fn to see SDL events before the engine handles them.
+func (e *Engine) OnEvent(fn EventFunc) {
+	e.eventHooks = append(e.eventHooks, fn)
+}
+
+// OnFrame registers fn to draw on top of every frame.
+func (e *Engine) OnFrame(fn FrameFunc) {
+	e.frameHooks = append(e.frameHooks, fn)
+}
+
+// Stop makes Run return after the current frame.
+func (e *Engine) Stop() {
+	e.running = false
+}
+
+// Run processes events and renders frames until the window is closed or
+// Stop is called.
+func (e *Engine) Run() {
+	for e.Step() {
+	}
+}
+
+// Step waits until there is something to do, handles all pending events
+// and redraws the scene if it changed. It returns false once the engine has
+// been asked to stop.
+func (e *Engine) Step() bool {
+	if wait := e.frames.wait(time.Now()); wait > 0 {
+		ms := int((wait + time.Millisecond - 1) / time.Millisecond)
+		if event := sdl.WaitEventTimeout(ms); event != nil {
+			e.handleEvent(event)
+		}
+	}
+	for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
+		e.handleEvent(event)
+	}
+	if !e.started {
+		e.started = true
+		e.startSystemInfo()
+		e.requestApproval(e.startScene)
+	}
+	e.runPending()
+	e.runSceneTimers(time.Now())
+	if !e.running {
+		return false
+	}
+
+	start := time.Now()
+	if !e.frames.ready(start) {
+		return true
+	}
+	e.frames.begin(start)
+	if next := e.nextSceneTimer(); !next.IsZero() {
+		e.frames.redrawAt(next)
+	}
+	e.Render()
+	e.backend.Present()
+	e.frames.record(time.Since(start))
+	return e.running
+}
+
+// Render draws the active scene and runs the frame hooks without presenting
+// it. Together with an OffscreenBackend it turns a scene into an image.
+func (e *Engine) Render() {
+	if e.approval != nil {
+		e.renderApproval()
+	} else {
+		e.renderActiveScene()
+	}
+	for _, fn := range e.frameHooks {
+		fn(e)
+	}
+	e.renderReloadErrors()
+}
+
+// post hands fn to the main loop, which runs it before the next frame.
+// Goroutines use it to touch engine state such as variables safely.
+func (e *Engine) post(fn func()) {
+	e.pendingMu.Lock()
+	e.pending = append(e.pending, fn)
+	e.pendingMu.Unlock()
+	e.Invalidate()
+}
+
+func (e *Engine) runPending() {
+	e.pendingMu.Lock()
+	pending := e.pending
+	e.pending = nil
+	e.pendingMu.Unlock()
+
+	for _, fn := range pending {
+		fn()
+	}
+}
+
+func (e *Engine) handleEvent(event sdl.Event) {
+	// Anything but pointer movement can change what is on screen.
+	if _, ok := event.(*sdl.MouseMotionEvent); !ok {
+		e.frames.dirty.Store(true)
+	}
+
+	for _, fn := range e.eventHooks {
+		if fn(e, event) {
+			return
+		}
+	}
+
+	config := e.config
+
+	// The approval dialog takes all input but quitting.
+	if _, quit := event.(*sdl.QuitEvent); e.approval != nil && !quit {
+		if action, ok := e.inputAction(event); ok {
+			e.handleApprovalAction(action)
+		}
+		return
+	}
+
+	// Keys typed into an input go to it rather than to the bindings.
+	if ev, ok := event.(*sdl.KeyboardEvent); ok && ev.Type == sdl.KEYDOWN && e.inputActiveElement != nil && !e.virtualKeyboardActive {
+		e.handleTextInput(ev)
+		return
+	}
+	if action, ok := e.inputAction(event); ok {
+		if e.virtualKeyboardActive {
+			e.handleVirtualKeyboardAction(action)
+		} else {
+			e.handleInputAction(action)
+		}
+		return
+	}
+
+	switch ev := event.(type) {
+	case *sdl.TextInputEvent: // Use pointer receiver
+		e.inputText += string(ev.Text[:])
+		e.updateInputVariable()
+	case *sdl.QuitEvent: // Use pointer receiver
+		e.running = false
+	case *sdl.WindowEvent:
+		if ev.Event == sdl.WINDOWEVENT_SIZE_CHANGED {
+			e.applyScaling()
+		}
+	case *sdl.MouseButtonEvent:
+		if ev.Button == sdl.BUTTON_LEFT && ev.Type == sdl.MOUSEBUTTONDOWN {
+			mouseX, mouseY := int32(ev.X), int32(ev.Y)
+
+			// Check menu buttons first
+			for sceneIndex, rect := range e.menuButtonRects {
+				if mouseX >= rect.X && mouseX <= rect.X+rect.W &&
+					mouseY >= rect.Y && mouseY <= rect.Y+rect.H {
+					// Change scene on click
+					e.enterScene(sceneIndex)
+					break // Exit after handling the click
+				}
+			}
+
+			// If not a menu button, check other elements
+			currentScene := config.Scenes[e.currentSceneIndex]
+			for i, element := range currentScene.Elements {
+				if !e.enabled(element) {
+					continue
+				}
+				// Input field handling
+				if element.Type == "input" {
+					widthStr := substituteVariables(string(element.Width), config)
+					width, _ := strconv.Atoi(widthStr)
+					if width == 0 {
+						width = 200
+					}
+					heightStr := substituteVariables(string(element.Height), config)
+					height, _ := strconv.Atoi(heightStr)
+					if height == 0 {
+						height = 50
+					}
+
+					if mouseX >= element.X && mouseX <= element.X+int32(width) &&
+						mouseY >= element.Y && mouseY <= element.Y+int32(height) {
+						e.handleInputSelection(&currentScene.Elements[i])
+					}
+				} else if element.Type == "button" {
+					font, _ := e.getFontAndSize(element.Font)
+					textWidth, textHeight := getTextDimensions(font, element.Text)
+					btnWidth := textWidth + 20
+					btnHeight := textHeight + 10
+
+					if string(element.Width) != "" {
+						widthStr := substituteVariables(string(element.Width), config)
+						w, _ := strconv.Atoi(widthStr)
+						btnWidth = int32(w)
+					}
+					if string(element.Height) != "" {
+						heightStr := substituteVariables(string(element.Height), config)
+						h, _ := strconv.Atoi(heightStr)
+						btnHeight = int32(h)
+					}
+
+					if mouseX >= element.X && mouseX <= element.X+btnWidth &&
+						mouseY >= element.Y && mouseY <= element.Y+btnHeight {
+						e.handleTrigger(element)
+					}
+				} else if element.Type == "dynamiclist" {
+					width, height := dynamicListSize(element, config)
+					if mouseX >= element.X && mouseX <= element.X+width &&
+						mouseY >= element.Y && mouseY <= element.Y+height {
+						e.selectedButtonIndex = i
+						e.cycleDynamicList(i, element)
+					}
+				}
+			}
+		}
+
+		//default:
+		//fmt.Printf("Unhandled event: %T\n", event)
+	}
+}
+
+func (e *Engine) activateSelectedElement() {
+	elements := e.config.Scenes[e.currentSceneIndex].Elements
+	if e.selectedButtonIndex >= 0 && e.selectedButtonIndex < len(elements) {
+		selectedElement := elements[e.selectedButtonIndex]
+		if !e.enabled(selectedElement) {
+			return
+		}
+		if selectedElement.Type == "input" {
+			e.handleInputSelection(&selectedElement)
+		} else if selectedElement.Type == "menu" {
+			// Menu is already handled by left/right navigation
+		} else {
+			e.triggerSelectedElement()
+		}
+	}
+}
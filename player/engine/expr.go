@@ -0,0 +1,293 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expressions are the conditions in visibleIf, enabledIf and if steps:
+//
+//	$lastGame
+//	$volume > 50 && !$muted
+//	$mode == "wifi" || ($retries >= 3 && $status != 'ok')
+//
+// Variables are looked up like in element text; a missing variable is the
+// empty string. Comparisons are numeric when both sides are numbers and
+// textual otherwise. "", "0" and "false" count as false.
+type expr interface {
+	eval(vars *Variables) string
+}
+
+type (
+	literalExpr  string
+	variableExpr string
+	notExpr      struct{ x expr }
+	binaryExpr   struct {
+		op   string
+		x, y expr
+	}
+)
+
+func (l literalExpr) eval(*Variables) string { return string(l) }
+
+func (v variableExpr) eval(vars *Variables) string {
+	value, _ := vars.Lookup(string(v))
+	return value
+}
+
+func (n notExpr) eval(vars *Variables) string {
+	return boolString(!truthy(n.x.eval(vars)))
+}
+
+func (b binaryExpr) eval(vars *Variables) string {
+	x := b.x.eval(vars)
+	switch b.op {
+	case "&&":
+		if !truthy(x) {
+			return "false"
+		}
+		return boolString(truthy(b.y.eval(vars)))
+	case "||":
+		if truthy(x) {
+			return "true"
+		}
+		return boolString(truthy(b.y.eval(vars)))
+	}
+
+	y := b.y.eval(vars)
+	cmp := strings.Compare(x, y)
+	if xn, err := strconv.ParseFloat(x, 64); err == nil {
+		if yn, err := strconv.ParseFloat(y, 64); err == nil {
+			switch {
+			case xn < yn:
+				cmp = -1
+			case xn > yn:
+				cmp = 1
+			default:
+				cmp = 0
+			}
+		}
+	}
+	switch b.op {
+	case "==":
+		return boolString(cmp == 0)
+	case "!=":
+		return boolString(cmp != 0)
+	case "<":
+		return boolString(cmp < 0)
+	case "<=":
+		return boolString(cmp <= 0)
+	case ">":
+		return boolString(cmp > 0)
+	default: // ">="
+		return boolString(cmp >= 0)
+	}
+}
+
+func truthy(s string) bool {
+	return s != "" && s != "0" && !strings.EqualFold(s, "false")
+}
+
+func boolString(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+// parseExpr compiles an expression.
+func parseExpr(src string) (expr, error) {
+	p := exprParser{src: src}
+	p.next()
+	x, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.tok != "" {
+		return nil, p.errorf("unexpected %q", p.tok)
+	}
+	return x, nil
+}
+
+type exprParser struct {
+	src string
+	pos int    // Offset after the current token
+	tok string // Current token, "" at the end
+	lit bool   // tok is a quoted string, already unquoted
+	err error  // Error from the tokenizer
+}
+
+func (p *exprParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s at offset %d in %q", fmt.Sprintf(format, args...), p.pos, p.src)
+}
+
+// next advances to the next token.
+func (p *exprParser) next() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+	p.tok, p.lit = "", false
+	if p.pos >= len(p.src) {
+		return
+	}
+
+	rest := p.src[p.pos:]
+	for _, op := range []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")"} {
+		if strings.HasPrefix(rest, op) {
+			p.tok = op
+			p.pos += len(op)
+			return
+		}
+	}
+
+	switch c := rest[0]; {
+	case c == '"' || c == '\'':
+		end := strings.IndexByte(rest[1:], c)
+		if end < 0 {
+			p.err = p.errorf("unterminated string")
+			p.pos = len(p.src)
+			return
+		}
+		p.tok, p.lit = rest[1:end+1], true
+		p.pos += end + 2
+	default:
+		end := 1
+		for end < len(rest) && isWordByte(rest[end]) {
+			end++
+		}
+		p.tok = rest[:end]
+		p.pos += end
+	}
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '.' || c == '-' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func (p *exprParser) parseOr() (expr, error) {
+	x, err := p.parseAnd()
+	for err == nil && p.tok == "||" && !p.lit {
+		p.next()
+		var y expr
+		y, err = p.parseAnd()
+		x = binaryExpr{op: "||", x: x, y: y}
+	}
+	return x, err
+}
+
+func (p *exprParser) parseAnd() (expr, error) {
+	x, err := p.parseNot()
+	for err == nil && p.tok == "&&" && !p.lit {
+		p.next()
+		var y expr
+		y, err = p.parseNot()
+		x = binaryExpr{op: "&&", x: x, y: y}
+	}
+	return x, err
+}
+
+func (p *exprParser) parseNot() (expr, error) {
+	if p.tok == "!" && !p.lit {
+		p.next()
+		x, err := p.parseNot()
+		return notExpr{x}, err
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (expr, error) {
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	switch p.tok {
+	case "==", "!=", "<", "<=", ">", ">=":
+		if p.lit {
+			break
+		}
+		op := p.tok
+		p.next()
+		y, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return binaryExpr{op: op, x: x, y: y}, nil
+	}
+	return x, nil
+}
+
+func (p *exprParser) parsePrimary() (expr, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	tok, lit := p.tok, p.lit
+	switch {
+	case lit:
+		p.next()
+		return literalExpr(tok), nil
+	case tok == "":
+		return nil, p.errorf("unexpected end of expression")
+	case tok == "(":
+		p.next()
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok != ")" || p.lit {
+			return nil, p.errorf("missing )")
+		}
+		p.next()
+		return x, nil
+	case tok[0] == '$':
+		if len(tok) == 1 {
+			return nil, p.errorf("missing variable name after $")
+		}
+		p.next()
+		return variableExpr(tok[1:]), nil
+	case tok == "true" || tok == "false":
+		p.next()
+		return literalExpr(tok), nil
+	}
+	if _, err := strconv.ParseFloat(tok, 64); err == nil {
+		p.next()
+		return literalExpr(tok), nil
+	}
+	return nil, p.errorf("unexpected %q (variables start with $, text needs quotes)", tok)
+}
+
+// condition evaluates src against the running config. An empty condition
+// is true; one that doesn't parse is false.
+func (e *Engine) condition(src string) bool {
+	if strings.TrimSpace(src) == "" {
+		return true
+	}
+	x, ok := e.conditions[src]
+	if !ok {
+		var err error
+		x, err = parseExpr(src)
+		if err != nil {
+			warnLimited(configLog, src, "invalid condition", "err", err)
+		}
+		e.conditions[src] = x
+	}
+	if x == nil {
+		return false
+	}
+	return truthy(x.eval(&e.config.Variables))
+}
+
+// visible reports whether element's visibleIf condition holds.
+func (e *Engine) visible(element Element) bool {
+	return e.condition(element.VisibleIf)
+}
+
+// enabled reports whether element is shown and its enabledIf condition
+// holds.
+func (e *Engine) enabled(element Element) bool {
+	return e.visible(element) && e.condition(element.EnabledIf)
+}
@@ -0,0 +1,104 @@
+package engine
+
+import "testing"
+
+func TestExprEval(t *testing.T) {
+	vars := &Variables{Custom: map[string]interface{}{
+		"volume": float64(60),
+		"muted":  false,
+		"mode":   "wifi",
+		"status": "ok",
+		"name":   "Ada",
+		"ten":    "10",
+		"nine":   "9",
+		"user":   map[string]interface{}{"name": "Ann"},
+	}}
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		// Truthiness
+		{"$mode", true},
+		{"$muted", false},
+		{"$missing", false},
+		{"0", false},
+		{"'false'", false},
+		{"''", false},
+
+		// Comparisons
+		{"$volume == 60", true},
+		{"$volume != 60", false},
+		{"$volume > 50", true},
+		{"$volume >= 60", true},
+		{"$volume < 60", false},
+		{"$volume <= 59.5", false},
+		{"$mode == 'wifi'", true},
+		{`$mode != "wifi"`, false},
+		{"$user.name == 'Ann'", true},
+
+		// Numbers compare as numbers, anything else as text
+		{"$ten > $nine", true},
+		{"'10' > '9'", true},
+		{"'10.0' == 10", true},
+		{"'abc' < 'abd'", true},
+		{"'b' > 'abc'", true},
+		{"$name > 5", true},
+
+		// Missing variables are the empty string
+		{"$missing == ''", true},
+		{"$missing < 'a'", true},
+		{"!$missing", true},
+
+		// ! binds tighter than &&, which binds tighter than ||
+		{"!$muted && $volume > 50", true},
+		{"!$mode && $volume > 50", false},
+		{"$muted && $muted || $mode", true},
+		{"$mode || $muted && $muted", true},
+		{"!$mode || $mode", true},
+		{"!!$mode", true},
+
+		// Parentheses
+		{"($mode || $muted) && $muted", false},
+		{"!($muted || $missing)", true},
+		{"$mode == 'wifi' || ($volume >= 3 && $status != 'ok')", true},
+		{"((($mode)))", true},
+	}
+	for _, tt := range tests {
+		x, err := parseExpr(tt.src)
+		if err != nil {
+			t.Errorf("parseExpr(%q): %v", tt.src, err)
+			continue
+		}
+		if got := truthy(x.eval(vars)); got != tt.want {
+			t.Errorf("%s is %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestExprErrors(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"   ",
+		"$",
+		"wifi",
+		"$mode ==",
+		"== 1",
+		"$a && ",
+		"|| $a",
+		"!",
+		"($mode",
+		"$mode)",
+		"()",
+		"'unterminated",
+		`"unterminated`,
+		"$a == 'b' 'c'",
+		"$a $b",
+		"$a == == $b",
+		"1 < 2 < 3",
+		"#",
+	} {
+		if x, err := parseExpr(src); err == nil {
+			t.Errorf("parseExpr(%q) = %v, want an error", src, x)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+const (
+	defaultTargetFPS = 60
+
+	// maxIdleWait bounds how long Step blocks waiting for input, so Stop
+	// and other state changes are noticed even on a silent event queue.
+	maxIdleWait = time.Second
+)
+
+// FrameStats describes how long the engine spends drawing frames. Only
+// frames that were actually redrawn are counted.
+type FrameStats struct {
+	Frames  uint64        // Frames drawn so far
+	Last    time.Duration // Render and present time of the last frame
+	Average time.Duration // Moving average over roughly the last 30 frames
+	Max     time.Duration // Slowest frame so far
+}
+
+// frameScheduler decides when a frame has to be drawn. The scene is only
+// redrawn when something marked it dirty (input, a variable change, an
+// animation) or a timed widget asked to be updated at a given moment.
+type frameScheduler struct {
+	targetFPS int
+	vsync     bool
+
+	dirty     atomic.Bool
+	deadline  time.Time // Earliest pending timed redraw, zero if none
+	lastFrame time.Time
+	stats     FrameStats
+}
+
+func (f *frameScheduler) init() {
+	f.targetFPS = defaultTargetFPS
+	f.vsync = true
+	f.dirty.Store(true) // Draw the first frame right away
+}
+
+func (f *frameScheduler) interval() time.Duration {
+	if f.targetFPS <= 0 {
+		return 0
+	}
+	return time.Second / time.Duration(f.targetFPS)
+}
+
+// redrawAt asks for a frame no later than t.
+func (f *frameScheduler) redrawAt(t time.Time) {
+	if f.deadline.IsZero() || t.Before(f.deadline) {
+		f.deadline = t
+	}
+}
+
+// due reports whether a frame should be drawn at now.
+func (f *frameScheduler) due(now time.Time) bool {
+	if f.dirty.Load() {
+		return true
+	}
+	return !f.deadline.IsZero() && !now.Before(f.deadline)
+}
+
+// wait returns how long to block for events before the next frame is due.
+func (f *frameScheduler) wait(now time.Time) time.Duration {
+	if f.due(now) {
+		// Keep dirty frames at the target rate.
+		if next := f.lastFrame.Add(f.interval()); now.Before(next) {
+			return next.Sub(now)
+		}
+		return 0
+	}
+	wait := maxIdleWait
+	if !f.deadline.IsZero() && f.deadline.Sub(now) < wait {
+		wait = f.deadline.Sub(now)
+	}
+	return wait
+}
+
+// ready reports whether a frame is due and the target frame rate allows
+// drawing it now.
+func (f *frameScheduler) ready(now time.Time) bool {
+	return f.due(now) && !now.Before(f.lastFrame.Add(f.interval()))
+}
+
+// begin clears the redraw requests a frame is about to satisfy. Anything
+// scheduled while the frame renders is kept for the next one.
+func (f *frameScheduler) begin(now time.Time) {
+	f.lastFrame = now
+	f.dirty.Store(false)
+	f.deadline = time.Time{}
+}
+
+func (f *frameScheduler) record(took time.Duration) {
+	f.stats.Frames++
+	f.stats.Last = took
+	if took > f.stats.Max {
+		f.stats.Max = took
+	}
+	if f.stats.Average == 0 {
+		f.stats.Average = took
+	} else {
+		f.stats.Average += (took - f.stats.Average) / 30
+	}
+}
+
+// SetTargetFPS caps how often the scene is redrawn while it is changing.
+// Zero or less removes the cap. The default is 60.
+func (e *Engine) SetTargetFPS(fps int) {
+	e.frames.targetFPS = fps
+}
+
+// SetVSync chooses whether the window renderer waits for the display's
+// vertical refresh. It is on by default and must be set before Open.
+func (e *Engine) SetVSync(enabled bool) {
+	e.frames.vsync = enabled
+}
+
+// FrameStats returns frame timing measurements for profiling.
+func (e *Engine) FrameStats() FrameStats {
+	return e.frames.stats
+}
+
+// Invalidate marks the scene as changed so the next Step redraws it. It is
+// safe to call from any goroutine; call it on every frame to keep an
+// animation running.
+func (e *Engine) Invalidate() {
+	if e.frames.dirty.Swap(true) {
+		return
+	}
+	// Wake up Step if it is blocked waiting for events.
+	sdl.PushEvent(&sdl.UserEvent{Type: sdl.USEREVENT})
+}
+
+// redrawAt schedules a frame for a widget that changes over time, like the
+// menu clock. It must be called while rendering.
+func (e *Engine) redrawAt(t time.Time) {
+	e.frames.redrawAt(t)
+}
@@ -0,0 +1,175 @@
+package engine
+
+import (
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+func (e *Engine) handleInputElement(element Element) {
+	renderer := e.renderer
+
+	e.virtualKeyboardActive = true
+	defer func() { e.virtualKeyboardActive = false }()
+
+	for e.virtualKeyboardActive {
+		renderer.SetDrawColor(249, 249, 249, 255)
+		renderer.Clear()
+		e.renderActiveScene()
+		e.renderKeyboard()
+		renderer.Present()
+
+		// Redraw at least twice a second for the cursor; otherwise sleep
+		// until a key is pressed.
+		for event := sdl.WaitEventTimeout(500); event != nil; event = sdl.PollEvent() {
+			if action, ok := e.inputAction(event); ok {
+				e.handleVirtualKeyboardAction(action)
+			}
+		}
+	}
+}
+
+func (e *Engine) renderKeyboard() {
+	if !e.virtualKeyboardActive {
+		return
+	}
+	renderer := e.renderer
+	screenWidth, screenHeight := e.LogicalSize()
+
+	// Dark overlay
+	renderer.SetDrawColor(0, 0, 0, 200)
+	renderer.FillRect(&sdl.Rect{X: 0, Y: 0, W: screenWidth, H: screenHeight})
+
+	keyWidth := int32(60)
+	keyHeight := int32(60)
+	padding := int32(10)
+	startX := (screenWidth - (10*keyWidth + 9*padding)) / 2
+	startY := int32(200)
+
+	for y, row := range e.keyboard {
+		rowStartX := startX
+		if y == 1 {
+			rowStartX += keyWidth / 2
+		}
+		if y == 2 {
+			rowStartX += keyWidth
+		}
+		if y == 3 {
+			rowStartX += keyWidth * 3
+		}
+
+		for x, key := range row {
+			// Draw key background
+			bgColor := sdl.Color{R: 255, G: 255, B: 255}
+			if x == e.keyboardPosX && y == e.keyboardPosY {
+				bgColor = sdl.Color{R: 0, G: 255, B: 0}
+			}
+			renderer.SetDrawColor(bgColor.R, bgColor.G, bgColor.B, 255)
+
+			rect := &sdl.Rect{
+				X: rowStartX + int32(x)*(keyWidth+padding),
+				Y: startY + int32(y)*(keyHeight+padding),
+				W: keyWidth,
+				H: keyHeight,
+			}
+			renderer.FillRect(rect)
+
+			// Draw key text
+			font, _ := e.getFontAndSize("medium")
+			e.renderText(font, key, sdl.Color{R: 0, G: 0, B: 0},
+				rect.X+keyWidth/2,
+				rect.Y+keyHeight/2,
+			)
+		}
+	}
+}
+
+func (e *Engine) isInputButtonSelected(element Element) bool {
+	return e.virtualKeyboardActive && e.inputActiveElement == &element
+}
+
+func (e *Engine) handleTextInput(event *sdl.KeyboardEvent) {
+	switch event.Keysym.Sym {
+	case sdl.K_BACKSPACE:
+		if len(e.inputTextBuffer) > 0 {
+			e.inputTextBuffer = e.inputTextBuffer[:len(e.inputTextBuffer)-1]
+		}
+	case sdl.K_RETURN, sdl.K_KP_ENTER:
+		// Finish input
+		e.inputActiveElement = nil
+		sdl.StopTextInput()
+	default:
+		// Characters handled by TextInputEvent
+	}
+	e.updateInputVariable()
+}
+
+func (e *Engine) handleInputSelection(element *Element) {
+	e.inputActiveElement = element
+	e.virtualKeyboardActive = true
+	e.handleInputElement(*element)
+	sdl.StartTextInput()
+}
+
+func (e *Engine) updateInputVariable() {
+	if e.inputActiveElement != nil && e.inputActiveElement.Variable != "" {
+		e.setVariable(e.inputActiveElement.Variable, e.inputTextBuffer)
+	}
+}
+
+func (e *Engine) initKeyboard() {
+	e.keyboard = [][]string{
+		{"Q", "W", "E", "R", "T", "Y", "U", "I", "O", "P"},
+		{"A", "S", "D", "F", "G", "H", "J", "K", "L"},
+		{"Z", "X", "C", "V", "B", "N", "M"},
+		{"SPACE", "BACK", "ENTER"},
+	}
+	e.keyboardPosX, e.keyboardPosY = 0, 0
+}
+
+// handleVirtualKeyboardAction moves over the on-screen keyboard and presses
+// its keys. back closes it, leaving the input to a physical keyboard.
+func (e *Engine) handleVirtualKeyboardAction(action InputAction) {
+	switch action {
+	case InputUp:
+		if e.keyboardPosY > 0 {
+			e.keyboardPosY--
+		}
+	case InputDown:
+		if e.keyboardPosY < len(e.keyboard)-1 {
+			e.keyboardPosY++
+		}
+	case InputLeft:
+		if e.keyboardPosX > 0 {
+			e.keyboardPosX--
+		}
+	case InputRight:
+		if e.keyboardPosX < len(e.keyboard[e.keyboardPosY])-1 {
+			e.keyboardPosX++
+		}
+	case InputConfirm:
+		e.handleKeyboardInput()
+	case InputBack:
+		e.virtualKeyboardActive = false
+	}
+	// Rows differ in length.
+	if last := len(e.keyboard[e.keyboardPosY]) - 1; e.keyboardPosX > last {
+		e.keyboardPosX = last
+	}
+}
+
+func (e *Engine) handleKeyboardInput() {
+	selectedKey := e.keyboard[e.keyboardPosY][e.keyboardPosX]
+	switch selectedKey {
+	case "SPACE":
+		e.inputTextBuffer += " "
+	case "BACK":
+		if len(e.inputTextBuffer) > 0 {
+			e.inputTextBuffer = e.inputTextBuffer[:len(e.inputTextBuffer)-1]
+		}
+	case "ENTER":
+		e.virtualKeyboardActive = false
+		e.inputActiveElement = nil
+	default:
+		e.inputTextBuffer += selectedKey
+	}
+	e.updateInputVariable()
+}
@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"hash/maphash"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Loggers for the parts of the engine, tagged with a "component" attribute
+// so a log can be filtered by where a message came from.
+var (
+	configLog  *slog.Logger
+	renderLog  *slog.Logger
+	inputLog   *slog.Logger
+	triggerLog *slog.Logger
+	processLog *slog.Logger
+)
+
+func init() {
+	SetLogger(slog.Default())
+}
+
+// SetLogger sends the engine's log output to l. By default it goes to
+// slog.Default() as it was when the package was loaded. Call it before
+// creating an engine.
+func SetLogger(l *slog.Logger) {
+	configLog = l.With("component", "config")
+	renderLog = l.With("component", "render")
+	inputLog = l.With("component", "input")
+	triggerLog = l.With("component", "trigger")
+	processLog = l.With("component", "process")
+}
+
+// repeatInterval is how often the same problem is logged again while it
+// keeps happening, for problems hit on every frame.
+const repeatInterval = time.Minute
+
+// maxLimitKeys caps how many problems the limiter keeps track of. Keys
+// come from the config, like image paths or whole data URLs, so they are
+// stored as hashes, and problems that are no longer held back are forgotten
+// once there are this many.
+const maxLimitKeys = 1024
+
+// limiter lets a message through once per repeatInterval per key and counts
+// the copies it held back.
+var limiter = newLogLimiter()
+
+type logLimiter struct {
+	mu   sync.Mutex
+	seed maphash.Seed
+	seen map[uint64]*limitState // Keyed by a hash of the key
+}
+
+func newLogLimiter() *logLimiter {
+	return &logLimiter{seed: maphash.MakeSeed(), seen: make(map[uint64]*limitState)}
+}
+
+type limitState struct {
+	next       time.Time
+	suppressed int
+}
+
+// allow reports whether the message for key may be logged now, and how many
+// copies were dropped since it last was.
+func (l *logLimiter) allow(key string, now time.Time) (bool, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	hash := maphash.String(l.seed, key)
+	state, ok := l.seen[hash]
+	if !ok {
+		if len(l.seen) >= maxLimitKeys {
+			l.forget(now)
+		}
+		state = &limitState{}
+		l.seen[hash] = state
+	}
+	if now.Before(state.next) {
+		state.suppressed++
+		return false, 0
+	}
+	suppressed := state.suppressed
+	state.next = now.Add(repeatInterval)
+	state.suppressed = 0
+	return true, suppressed
+}
+
+// forget drops the problems whose interval is over, or all of them if that
+// doesn't make room.
+func (l *logLimiter) forget(now time.Time) {
+	for hash, state := range l.seen {
+		if !now.Before(state.next) {
+			delete(l.seen, hash)
+		}
+	}
+	if len(l.seen) >= maxLimitKeys {
+		clear(l.seen)
+	}
+}
+
+// warnLimited logs a warning that would otherwise repeat every frame. key
+// identifies the problem, for example the name of a missing variable.
+func warnLimited(l *slog.Logger, key, msg string, args ...any) {
+	ok, suppressed := limiter.allow(msg+"\x00"+key, time.Now())
+	if !ok {
+		return
+	}
+	if suppressed > 0 {
+		args = append(args, "repeated", suppressed)
+	}
+	l.Warn(msg, args...)
+}
@@ -0,0 +1,34 @@
+package engine
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogLimiter(t *testing.T) {
+	l := newLogLimiter()
+	start := time.Now()
+
+	key := "data:image/png;base64," + strings.Repeat("A", 100000)
+	if ok, _ := l.allow(key, start); !ok {
+		t.Fatal("first message held back")
+	}
+	for i := 1; i <= 3; i++ {
+		if ok, _ := l.allow(key, start.Add(time.Duration(i)*time.Second)); ok {
+			t.Fatalf("repeat %d let through", i)
+		}
+	}
+	if ok, suppressed := l.allow(key, start.Add(repeatInterval)); !ok || suppressed != 3 {
+		t.Errorf("after the interval: %v with %d held back, want true with 3", ok, suppressed)
+	}
+
+	// However many different problems there are, only so many are kept.
+	for i := 0; i < 3*maxLimitKeys; i++ {
+		l.allow("missing-"+strconv.Itoa(i), start.Add(time.Duration(i)*time.Millisecond))
+		if len(l.seen) > maxLimitKeys {
+			t.Fatalf("%d keys kept, want at most %d", len(l.seen), maxLimitKeys)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// saveDelay batches variable changes, such as typing into an input, into
+// one write of the state file.
+const saveDelay = time.Second
+
+// persistentVariables returns the lower-cased names of the variables config
+// keeps across launches: those in persistentVariables and those written by
+// elements and actions marked persist.
+func persistentVariables(config *Config) map[string]bool {
+	names := make(map[string]bool)
+	add := func(name string) {
+		if name != "" {
+			names[strings.ToLower(name)] = true
+		}
+	}
+	addActions := func(actions []Action) {
+		for _, action := range flattenActions(actions) {
+			if !action.Persist {
+				continue
+			}
+			add(writtenVariable(action))
+			add(action.ExternalAppReturn)
+		}
+	}
+
+	for _, name := range config.PersistentVariables {
+		add(name)
+	}
+	for _, scene := range config.Scenes {
+		for _, element := range scene.Elements {
+			if element.Persist {
+				switch element.Type {
+				case "input", "dynamiclist":
+					add(element.Variable)
+				}
+				add(writtenVariable(Action{Trigger: element.Trigger, TriggerTarget: element.TriggerTarget}))
+				add(element.ExternalAppReturn)
+			}
+			addActions(element.Actions)
+		}
+		addActions(scene.OnEnter)
+		addActions(scene.OnExit)
+		for _, timer := range scene.OnTimer {
+			addActions(timer.Actions)
+		}
+	}
+	return names
+}
+
+// setVariable stores a value in the custom variables. An existing variable
+// keeps its spelling, since lookups ignore case. Changes to persistent
+// variables are saved to the state file shortly after.
+func (e *Engine) setVariable(name string, value interface{}) {
+	custom := e.config.Variables.Custom
+	for key := range custom {
+		if strings.EqualFold(key, name) {
+			name = key
+			break
+		}
+	}
+	custom[name] = value
+	if e.persistent[strings.ToLower(name)] {
+		e.scheduleSave()
+	}
+}
+
+// SetStateFile makes the engine keep persistent variables in the JSON file
+// at path and loads the values saved there by an earlier run. A missing file
+// is not an error. Call it after New and before Run.
+func (e *Engine) SetStateFile(path string) error {
+	e.statePath = ""
+	defer func() { e.statePath = path }() // Restoring values is not a change
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var saved map[string]interface{}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	for name, value := range saved {
+		// Only restore what the config still asks to keep.
+		if e.persistent[strings.ToLower(name)] {
+			e.setVariable(name, value)
+		}
+	}
+	return nil
+}
+
+func (e *Engine) scheduleSave() {
+	if e.statePath == "" || e.stateDirty {
+		return
+	}
+	e.stateDirty = true
+	e.saveTimer = time.AfterFunc(saveDelay, func() { e.post(e.saveState) })
+}
+
+// saveState writes the persistent variables to the state file. It writes a
+// temporary file and renames it, so a crash never leaves a half-written
+// state behind.
+func (e *Engine) saveState() {
+	if !e.stateDirty {
+		return
+	}
+	e.stateDirty = false
+
+	values := make(map[string]interface{})
+	for name, value := range e.config.Variables.Custom {
+		if e.persistent[strings.ToLower(name)] {
+			values[name] = value
+		}
+	}
+	if err := writeFileAtomic(e.statePath, values); err != nil {
+		configLog.Error("cannot save variables", "path", e.statePath, "err", err)
+	}
+}
+
+func writeFileAtomic(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // Fails harmlessly once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// resetVariables puts variables back to their values in the config file,
+// removing those the config doesn't define. names is a comma-separated
+// list; empty means every persistent variable.
+func (e *Engine) resetVariables(names string) {
+	reset := make(map[string]bool)
+	if strings.TrimSpace(names) == "" {
+		reset = e.persistent
+	} else {
+		for _, name := range strings.Split(names, ",") {
+			reset[strings.ToLower(strings.TrimSpace(name))] = true
+		}
+	}
+
+	custom := e.config.Variables.Custom
+	for name := range custom {
+		if reset[strings.ToLower(name)] {
+			delete(custom, name)
+		}
+	}
+	for name, value := range e.fileCustom {
+		if reset[strings.ToLower(name)] {
+			custom[name] = value
+		}
+	}
+	if e.resources != nil {
+		e.resources.Clear() // Image paths may have changed
+	}
+	e.dynamicLists = make(map[string]*dynamicList)
+	e.listLoads = make(map[string]bool)
+
+	for name := range reset {
+		if e.persistent[name] {
+			e.scheduleSave()
+			break
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Policy limits what an app may do. The player can be given one, and a
+// config can restrict itself further with its own under "security"; a
+// command or file has to pass both. A field left out allows everything, so
+// the zero Policy changes nothing.
+type Policy struct {
+	// Programs external_app and video playback may run. A bare name
+	// ("retroarch") allows a program run by that name from $PATH; a path,
+	// which may use * wildcards, allows the programs it matches.
+	Executables []string `json:"executables"`
+
+	// AllowShell permits sh -c commands: external_apps with shell set and
+	// the commands of collapsedlist, dynamiclist and console elements. A
+	// shell can run anything, whatever Executables says.
+	AllowShell *bool `json:"allowShell"`
+
+	// Directories images, fonts, videos and working directories must be
+	// in. The config's own directory is always allowed.
+	PathRoots []string `json:"pathRoots"`
+
+	// Confirm asks the user before the app runs any command for the first
+	// time, and again whenever its commands change.
+	Confirm bool `json:"confirm"`
+
+	dir string // Relative paths above are relative to dir, or to the working directory if empty
+}
+
+// LoadPolicy reads a policy file. Relative paths in it are relative to the
+// file's directory.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if p.dir, err = filepath.Abs(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// abs makes path absolute against base, or the working directory.
+func abs(base, path string) string {
+	if !filepath.IsAbs(path) && base != "" {
+		path = filepath.Join(base, path)
+	}
+	if a, err := filepath.Abs(path); err == nil {
+		path = a
+	}
+	return path
+}
+
+// allowsShell reports why sh -c commands are not allowed, or nil.
+func (p *Policy) allowsShell() error {
+	if p.AllowShell != nil && !*p.AllowShell {
+		return errors.New("shell commands are not allowed")
+	}
+	return nil
+}
+
+// allowsProgram reports why program, as it would be run from dir, is not
+// allowed, or nil.
+func (p *Policy) allowsProgram(program, dir string) error {
+	if p.Executables == nil {
+		return nil
+	}
+	bare := !strings.ContainsAny(program, `/\`)
+	path := abs(dir, program)
+	for _, allowed := range p.Executables {
+		if !strings.ContainsAny(allowed, `/\`) {
+			if bare && allowed == program {
+				return nil
+			}
+			continue
+		}
+		if ok, _ := filepath.Match(abs(p.dir, allowed), path); ok && !bare {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not in the allowed executables", program)
+}
+
+// allowsPath reports why the file or directory at path may not be used, or
+// nil. Symbolic links are followed, so they can't lead out of the roots.
+func (p *Policy) allowsPath(path string) error {
+	if p.PathRoots == nil {
+		return nil
+	}
+	target := realPath(abs("", path))
+	roots := append([]string{"."}, p.PathRoots...)
+	for i, root := range roots {
+		if i > 0 {
+			root = abs(p.dir, root)
+		}
+		rel, err := filepath.Rel(realPath(abs("", root)), target)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is outside the allowed paths", path)
+}
+
+// realPath resolves the symbolic links in path, if it exists.
+func realPath(path string) string {
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		return real
+	}
+	return path
+}
+
+// SetPolicy makes the engine enforce policy on top of the config's own.
+// Relative paths in it are relative to the working directory unless it was
+// loaded with LoadPolicy. Call it before Run.
+func (e *Engine) SetPolicy(policy *Policy) {
+	e.policy = policy
+}
+
+// SetCommandsEnabled(false) stops the engine from running any command, as
+// if a policy refused them all: apps, videos, and the commands of lists and
+// consoles. Snapshots use it so rendering an app has no side effects.
+func (e *Engine) SetCommandsEnabled(enabled bool) {
+	e.noCommands = !enabled
+}
+
+// policies returns the policies in force: the player's and the config's.
+func (e *Engine) policies() []*Policy {
+	var list []*Policy
+	if e.policy != nil {
+		list = append(list, e.policy)
+	}
+	if e.config.Security != nil {
+		list = append(list, e.config.Security)
+	}
+	return list
+}
+
+// isShell reports whether cmd is a sh -c command.
+func isShell(cmd *exec.Cmd) bool {
+	return len(cmd.Args) > 1 && filepath.Base(cmd.Args[0]) == "sh" && cmd.Args[1] == "-c"
+}
+
+// commandAllowed reports why cmd may not run, or nil. Nothing runs while
+// commands are disabled or the user hasn't allowed the app's commands yet.
+func (e *Engine) commandAllowed(cmd *exec.Cmd) error {
+	if e.noCommands {
+		return errors.New("commands are disabled")
+	}
+	if e.approval != nil {
+		return errors.New("the app's commands have not been allowed yet")
+	}
+	for _, p := range e.policies() {
+		var err error
+		if isShell(cmd) {
+			err = p.allowsShell()
+		} else {
+			err = p.allowsProgram(cmd.Args[0], cmd.Dir)
+		}
+		if err == nil && cmd.Dir != "" {
+			err = p.allowsPath(cmd.Dir)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pathAllowed reports why the file at path may not be used, or nil.
+func (e *Engine) pathAllowed(path string) error {
+	for _, p := range e.policies() {
+		if err := p.allowsPath(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// confirmCommands reports whether the user has to allow the app's commands.
+func (e *Engine) confirmCommands() bool {
+	for _, p := range e.policies() {
+		if p.Confirm {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,219 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// listCommandTimeout bounds the commands that fill collapsedlist and
+// dynamiclist elements.
+const listCommandTimeout = 30 * time.Second
+
+// maxCapture is how much of a command's stdout and stderr is kept; the rest
+// is dropped.
+const maxCapture = 1 << 20
+
+// processResult is what a finished command left behind.
+type processResult struct {
+	stdout   []byte
+	stderr   []byte
+	exitCode int // -1 if the command didn't start or was killed
+	err      error
+}
+
+// startProcess runs cmd on a goroutine of its own, capturing its output,
+// and calls done with the result on the main loop. Commands the policies
+// refuse fail without running. Writers already set on
+// cmd get the output too, as it arrives. Nothing on the main loop ever waits
+// for a child process.
+func (e *Engine) startProcess(cmd *exec.Cmd, done func(processResult)) {
+	if err := e.commandAllowed(cmd); err != nil {
+		processLog.Warn("command refused", "command", cmd.Path, "err", err)
+		e.post(func() { done(processResult{exitCode: -1, err: err}) })
+		return
+	}
+	stdout, stderr := &capture{}, &capture{}
+	cmd.Stdout, cmd.Stderr = tee(stdout, cmd.Stdout), tee(stderr, cmd.Stderr)
+	ownProcessGroup(cmd)
+	// Children that left the group can still hold the pipes open.
+	cmd.WaitDelay = time.Second
+
+	go func() {
+		err := cmd.Run()
+		result := processResult{stdout: stdout.Bytes(), stderr: stderr.Bytes(), exitCode: -1, err: err}
+		if cmd.ProcessState != nil {
+			result.exitCode = cmd.ProcessState.ExitCode()
+		}
+		e.post(func() { done(result) })
+	}()
+}
+
+func tee(w, also io.Writer) io.Writer {
+	if also == nil {
+		return w
+	}
+	return io.MultiWriter(w, also)
+}
+
+// capture is a buffer that stops growing at maxCapture.
+type capture struct {
+	bytes.Buffer
+}
+
+func (c *capture) Write(p []byte) (int, error) {
+	if room := maxCapture - c.Len(); room < len(p) {
+		c.Buffer.Write(p[:max(room, 0)])
+		return len(p), nil
+	}
+	return c.Buffer.Write(p)
+}
+
+// job is an external_app started with a job name. Its state is kept in the
+// variable of that name: running, exitCode, stdout, stderr and error.
+type job struct {
+	cancel  context.CancelFunc
+	running bool
+}
+
+// startApp runs an external_app in the background and calls appReturned
+// once it exits. With a job name its state is published as it goes, and
+// cancel_job can stop it. It returns false if nothing was started.
+func (e *Engine) startApp(element Element, resume func()) bool {
+	if e.jobRunning(element.Job) {
+		warnLimited(processLog, "job:"+strings.ToLower(element.Job), "job is already running", "job", element.Job)
+		return false
+	}
+
+	ctx, cancel := jobContext(element.Timeout)
+	cmd, err := e.appCommand(ctx, element)
+	if err != nil {
+		cancel()
+		processLog.Error("cannot start external app", "target", element.TriggerTarget, "err", err)
+		if element.Job != "" {
+			e.setJobState(element.Job, false, -1, processResult{}, err.Error())
+		}
+		return false
+	}
+
+	processLog.Debug("starting external app", "command", cmd.Path, "job", element.Job)
+	e.startJob(element.Job, ctx, cancel, cmd, func(result processResult, reason string) {
+		e.appReturned(element, result.exitCode, resume)
+	})
+	return true
+}
+
+// jobContext returns the context a job runs under: cancelled by cancel_job
+// or, if timeout is positive, after that many milliseconds.
+func jobContext(timeout int) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(context.Background(), time.Duration(timeout)*time.Millisecond)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// jobRunning reports whether the named job is running.
+func (e *Engine) jobRunning(name string) bool {
+	j := e.jobs[strings.ToLower(name)]
+	return name != "" && j != nil && j.running
+}
+
+// startJob runs cmd, which was built with ctx, as the named job and calls
+// done once it exits, with why it was killed if it was. An empty name runs
+// it without publishing its state.
+func (e *Engine) startJob(name string, ctx context.Context, cancel context.CancelFunc, cmd *exec.Cmd, done func(result processResult, reason string)) {
+	key := strings.ToLower(name)
+	j := &job{cancel: cancel, running: true}
+	if name != "" {
+		e.jobs[key] = j
+		e.setJobState(name, true, nil, processResult{}, "")
+	}
+
+	e.startProcess(cmd, func(result processResult) {
+		j.running = false
+		reason := ""
+		switch {
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			reason = "timeout"
+		case errors.Is(ctx.Err(), context.Canceled):
+			reason = "canceled"
+		case result.err != nil && result.exitCode < 0:
+			reason = result.err.Error()
+		}
+		cancel()
+		if reason != "" {
+			processLog.Warn("command did not finish", "command", cmd.Path, "job", name, "reason", reason)
+		}
+		if name != "" && e.jobs[key] == j {
+			e.setJobState(name, false, result.exitCode, result, reason)
+		}
+		done(result, reason)
+	})
+}
+
+// setJobState stores a job's state in its variable. exitCode is nil while
+// the job runs.
+func (e *Engine) setJobState(name string, running bool, exitCode interface{}, result processResult, reason string) {
+	e.setVariable(name, map[string]interface{}{
+		"running":  running,
+		"exitCode": exitCode,
+		"stdout":   strings.TrimRight(string(result.stdout), "\n"),
+		"stderr":   strings.TrimRight(string(result.stderr), "\n"),
+		"error":    reason,
+	})
+}
+
+// cancelJob kills the named job if it is running. Its onReturn actions
+// still run, with error set to "canceled".
+func (e *Engine) cancelJob(name string) {
+	if j := e.jobs[strings.ToLower(name)]; j != nil && j.running {
+		j.cancel()
+	}
+}
+
+// cancelJobs kills every running job.
+func (e *Engine) cancelJobs() {
+	for _, j := range e.jobs {
+		if j.running {
+			j.cancel()
+		}
+	}
+}
+
+// loadCollapsedList runs a collapsedlist's command in the background and
+// stores the items it prints in the list variable. It runs once per visit
+// to the scene, however long the command takes or if it fails, and not at
+// all while commands are disabled. Items that arrive after the config was
+// reloaded are dropped; the new config loads its own.
+func (e *Engine) loadCollapsedList(element Element) {
+	key := strings.ToLower(element.ListVariable)
+	if e.listLoads[key] || e.noCommands {
+		return
+	}
+	e.listLoads[key] = true
+
+	config := e.config
+	ctx, cancel := context.WithTimeout(context.Background(), listCommandTimeout)
+	command := substituteVariablesWith(element.Command, e.config, shellQuote)
+	e.startProcess(exec.CommandContext(ctx, "sh", "-c", command), func(result processResult) {
+		cancel()
+		if e.config != config {
+			return
+		}
+		if result.err != nil {
+			processLog.Error("list command failed", "command", command, "err", result.err)
+			return
+		}
+		var items []CollapsedListItem
+		if err := json.Unmarshal(result.stdout, &items); err != nil {
+			processLog.Error("list command printed invalid JSON", "command", command, "err", err)
+			return
+		}
+		e.setVariable(element.ListVariable, items)
+	})
+}
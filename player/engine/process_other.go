@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package engine
+
+import "os/exec"
+
+// ownProcessGroup is not implemented on this platform; cancelling a command
+// only kills the process itself.
+func ownProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,21 @@
+//go:build linux || darwin
+
+package engine
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// ownProcessGroup makes cancelling cmd kill everything it started, not
+// just the shell. Only commands made with exec.CommandContext can be
+// cancelled; others are left to run.
+func ownProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if cmd.Cancel == nil {
+		return // Setting Cancel without a context makes Start fail
+	}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
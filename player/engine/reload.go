@@ -0,0 +1,241 @@
+package engine
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// watchInterval is how often Watch checks the config file for changes.
+const watchInterval = 500 * time.Millisecond
+
+// maxOverlayLines caps how many problems the reload overlay lists.
+const maxOverlayLines = 8
+
+// Watch reloads the config from path whenever the file changes, until Close
+// is called. Reloads happen on the main loop; a config that fails to load
+// or validate leaves the running one in place and shows the problems in an
+// overlay until the file is fixed.
+func (e *Engine) Watch(path string) {
+	if e.watchStop != nil {
+		close(e.watchStop)
+	}
+	stop := make(chan struct{})
+	e.watchStop = stop
+
+	go func() {
+		last, _ := os.Stat(path)
+		ticker := time.NewTicker(watchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			info, err := os.Stat(path)
+			if err != nil || (last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size()) {
+				continue
+			}
+			last = info
+			configLog.Info("config changed, reloading", "path", path)
+			e.post(func() { e.Reload(path) })
+		}
+	}()
+}
+
+// Reload loads and validates the config at path and, if it has no errors,
+// swaps it in for the running one. The active scene (matched by name), the
+// focused element and variables set at runtime are kept. Problems are
+// returned and, if there are errors, shown on screen instead.
+func (e *Engine) Reload(path string) ([]Problem, error) {
+	config, err := LoadConfig(path)
+	if err != nil {
+		e.reloadErrors = []string{fmt.Sprintf("%s: %v", filepath.Base(path), err)}
+		e.Invalidate()
+		return nil, err
+	}
+
+	v := validator{config: config, dir: filepath.Dir(path), triggers: e.triggers}
+	problems := v.run()
+	if HasErrors(problems) {
+		e.reloadErrors = nil
+		for _, p := range problems {
+			if p.Severity == SeverityError {
+				e.reloadErrors = append(e.reloadErrors, p.String())
+			}
+		}
+		e.Invalidate()
+		return problems, fmt.Errorf("%s has errors", path)
+	}
+
+	e.swapConfig(config)
+	e.reloadErrors = nil
+	e.Invalidate()
+	return problems, nil
+}
+
+// findScene finds the scene called name in the active config and checks
+// that the element at selected can still be focused there, picking the
+// first one that can if not.
+func (e *Engine) findScene(name string, selected int) (sceneEntry, bool) {
+	for i, scene := range e.config.Scenes {
+		if scene.Name != name {
+			continue
+		}
+		if selected < 0 || selected >= len(scene.Elements) || !e.focusable(scene.Elements[selected]) {
+			selected = e.findFirstSelectableElement(scene)
+		}
+		return sceneEntry{i, selected}, true
+	}
+	return sceneEntry{}, false
+}
+
+// swapConfig replaces the running config with config, carrying over the
+// state the user would lose by restarting the player.
+func (e *Engine) swapConfig(config *Config) {
+	old := e.config
+	fileCustom := copyCustom(config.Variables.Custom)
+
+	// Keep variables that were created or changed while the player ran,
+	// unless the new file changes that variable itself. Lists filled by
+	// commands are left out: the new config runs its commands again.
+	commandLists := commandListVariables(old)
+	for key, value := range old.Variables.Custom {
+		if commandLists[strings.ToLower(key)] {
+			continue
+		}
+		loaded, inFile := e.fileCustom[key]
+		if inFile && reflect.DeepEqual(loaded, value) {
+			continue
+		}
+		if newValue, ok := fileCustom[key]; ok && !reflect.DeepEqual(newValue, loaded) {
+			continue
+		}
+		config.Variables.Custom[key] = value
+	}
+
+	config.Variables.System = old.Variables.System
+
+	// Indices may have changed, so scenes are found again by name.
+	sceneName := old.Scenes[e.currentSceneIndex].Name
+	stackNames := make([]string, len(e.sceneStack))
+	for i, entry := range e.sceneStack {
+		stackNames[i] = old.Scenes[entry.index].Name
+	}
+
+	e.config = config
+	e.fileCustom = fileCustom
+	e.persistent = persistentVariables(config)
+	e.useBindings()
+	if current, ok := e.findScene(sceneName, e.selectedButtonIndex); ok {
+		// Scenes a modal was pushed over stay below it; ones the new file
+		// dropped are left out.
+		stack := e.sceneStack[:0]
+		for i, entry := range e.sceneStack {
+			if found, ok := e.findScene(stackNames[i], entry.selected); ok {
+				stack = append(stack, found)
+			}
+		}
+		e.sceneStack = stack
+		e.currentSceneIndex, e.selectedButtonIndex = current.index, current.selected
+	} else {
+		e.sceneStack = nil
+		e.currentSceneIndex = 0
+		e.selectedButtonIndex = e.findFirstSelectableElement(config.Scenes[0])
+	}
+
+	// Editing state points into the old config's elements.
+	e.inputActiveElement = nil
+	e.virtualKeyboardActive = false
+	e.menuButtonRects = make(map[int]sdl.Rect)
+	e.dynamicLists = make(map[string]*dynamicList)
+	e.listLoads = make(map[string]bool)
+	e.stopConsoles()
+	e.videoPlayed = false
+	if e.started {
+		e.resetSceneTimers(time.Now())
+		if config.SystemInfo != old.SystemInfo || !maps.Equal(referencedVariables(config), referencedVariables(old)) {
+			e.startSystemInfo()
+		}
+		// The new file may run other commands.
+		if e.approval != nil {
+			e.approval.commands, e.approval.scroll = appCommands(config), 0
+		} else {
+			e.requestApproval(nil)
+		}
+	}
+
+	// Images may have been replaced on disk along with the config.
+	if e.resources != nil {
+		e.resources.Clear()
+	}
+	if e.renderer != nil {
+		e.applyScaling()
+	}
+	if window := e.Window(); window != nil && config.Title != old.Title {
+		window.SetTitle(config.Title)
+	}
+}
+
+// commandListVariables returns the lower-cased variables that the commands
+// of config's collapsedlists fill.
+func commandListVariables(config *Config) map[string]bool {
+	lists := make(map[string]bool)
+	for _, scene := range config.Scenes {
+		for _, element := range scene.Elements {
+			if element.Type == "collapsedlist" && element.Command != "" && element.ListVariable != "" {
+				lists[strings.ToLower(element.ListVariable)] = true
+			}
+		}
+	}
+	return lists
+}
+
+func copyCustom(custom map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(custom))
+	for key, value := range custom {
+		c[key] = value
+	}
+	return c
+}
+
+// renderReloadErrors draws the problems of the last failed reload over the
+// scene.
+func (e *Engine) renderReloadErrors() {
+	if len(e.reloadErrors) == 0 {
+		return
+	}
+	lines := e.reloadErrors
+	if len(lines) > maxOverlayLines {
+		more := len(lines) - maxOverlayLines
+		lines = append(lines[:maxOverlayLines:maxOverlayLines], fmt.Sprintf("... and %d more", more))
+	}
+	lines = append([]string{"Config not reloaded:"}, lines...)
+
+	font, _ := e.getFontAndSize("")
+	if font == nil {
+		return
+	}
+	_, lineHeight := getTextDimensions(font, "Ag")
+	screenWidth, _ := e.LogicalSize()
+
+	e.renderer.SetDrawColor(120, 0, 0, 220)
+	e.renderer.FillRect(&sdl.Rect{X: 0, Y: 0, W: screenWidth, H: int32(len(lines))*lineHeight + 20})
+
+	white := sdl.Color{R: 255, G: 255, B: 255, A: 255}
+	for i, line := range lines {
+		// renderText would substitute $variables in the messages.
+		texture, w, h, err := e.resources.Text(font, line, white)
+		if err != nil {
+			continue
+		}
+		e.renderer.Copy(texture, nil, &sdl.Rect{X: 10, Y: 10 + int32(i)*lineHeight, W: w, H: h})
+	}
+}
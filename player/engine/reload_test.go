@@ -0,0 +1,56 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestReloadVariables checks which runtime variables survive a reload:
+// values set while the player ran stay, lists filled by commands don't, so
+// that the new config's commands fill them again.
+func TestReloadVariables(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jukaconfig.json")
+	write := func(title string) {
+		t.Helper()
+		config := `{
+  "title": "` + title + `",
+  "variables": { "score": 1, "theme": "dark" },
+  "scenes": [{ "name": "Home", "elements": [
+    { "type": "collapsedlist", "command": "printf '[]'", "listVariable": "games", "x": 0, "y": 0 }
+  ] }]
+}`
+		if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("One")
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := New(config)
+	custom := e.config.Variables.Custom
+	custom["score"] = float64(5)
+	custom["games"] = []CollapsedListItem{{Title: "Old"}}
+	custom["lastGame"] = "Tetris"
+
+	write("Two")
+	if _, err := e.Reload(path); err != nil {
+		t.Fatal(err)
+	}
+	custom = e.config.Variables.Custom
+	if got := custom["score"]; got != float64(5) {
+		t.Errorf("score is %v after the reload, want 5", got)
+	}
+	if got := custom["lastGame"]; got != "Tetris" {
+		t.Errorf("lastGame is %v after the reload, want Tetris", got)
+	}
+	if got := custom["theme"]; got != "dark" {
+		t.Errorf("theme is %v after the reload, want dark", got)
+	}
+	if got, ok := custom["games"]; ok {
+		t.Errorf("games is %v after the reload, want it left for the command", got)
+	}
+}
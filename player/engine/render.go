@@ -0,0 +1,583 @@
+package engine
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"github.com/veandco/go-sdl2/ttf"
+)
+
+func resolveColor(config *Config, colorName string, defaultColor sdl.Color) sdl.Color {
+	if strings.HasPrefix(colorName, "$") {
+		colorValue := config.Variables.Get(colorName[1:])
+		parts := strings.Split(colorValue, ",")
+		if len(parts) == 3 {
+			r, _ := strconv.Atoi(parts[0])
+			g, _ := strconv.Atoi(parts[1])
+			b, _ := strconv.Atoi(parts[2])
+			return sdl.Color{R: uint8(r), G: uint8(g), B: uint8(b), A: 255}
+		}
+		return defaultColor
+	}
+
+	if colorName != "" {
+		r, g, b, ok := parseHexColor(colorName)
+		if !ok {
+			warnLimited(renderLog, colorName, "invalid color", "color", colorName)
+			return defaultColor
+		}
+		return sdl.Color{R: r, G: g, B: b, A: 255}
+	}
+	return defaultColor
+}
+
+func (e *Engine) renderBackground() {
+	if bgTexture := e.resolveBackground(); bgTexture != nil {
+		screenWidth, screenHeight := e.LogicalSize()
+		e.renderer.Copy(bgTexture, nil, &sdl.Rect{X: 0, Y: 0, W: screenWidth, H: screenHeight})
+	}
+}
+
+func (e *Engine) resolveBackground() *sdl.Texture {
+	if e.config.Variables.BackgroundImage != "" {
+		return e.loadTexture(substituteVariables(e.config.Variables.BackgroundImage, e.config))
+	}
+
+	// Default background
+	e.renderer.SetDrawColor(32, 32, 32, 255)
+	e.renderer.Clear()
+	return nil
+}
+
+// substituteVariables replaces $name, $name.path and ${name:%fmt|default}
+// references in text. References without a default that can't be resolved
+// become MISSING_VAR.
+func substituteVariables(text string, config *Config) string {
+	return substituteVariablesWith(text, config, nil)
+}
+
+// substituteVariablesWith is substituteVariables with every value passed
+// through escape first, unless escape is nil. With an escape, references
+// that can't be resolved are left as written: in a shell script they are
+// the shell's own variables, like $1 or $HOME.
+func substituteVariablesWith(text string, config *Config, escape func(string) string) string {
+	return variablePattern.ReplaceAllStringFunc(text, func(m string) string {
+		sub := variablePattern.FindStringSubmatch(m)
+		value, ok := config.Variables.expand(sub)
+		if !ok && escape != nil {
+			return m
+		}
+		if !ok {
+			name := parseVariableRef(sub).Name
+			warnLimited(configLog, strings.ToLower(name), "missing variable", "name", name)
+			return "MISSING_VAR"
+		}
+		if escape != nil {
+			return escape(value)
+		}
+		return value
+	})
+}
+
+// fontSpec maps a font key such as "title" or "small" to a font file and
+// point size.
+func fontSpec(config *Config, fontName string) (string, int) {
+	// Get font path (case-insensitive)
+	fontPath := "Roboto-Black.ttf" // Default fallback
+	size := 24
+
+	// Find matching font key
+	for key, path := range config.Variables.Fonts {
+		if strings.EqualFold(key, fontName) {
+			fontPath = path
+			break
+		}
+	}
+
+	// Find matching font size key
+	for key, val := range config.Variables.FontSizes {
+		if strings.EqualFold(key, fontName) {
+			size = val
+			break
+		}
+	}
+	return fontPath, size
+}
+
+// getFontAndSize returns the cached font for a font key. The font belongs to
+// the resource cache; callers must not close it.
+func (e *Engine) getFontAndSize(fontName string) (*ttf.Font, int) {
+	fontPath, size := fontSpec(e.config, fontName)
+	font, err := e.resources.Font(fontPath, size)
+	if err != nil {
+		warnLimited(renderLog, fontPath, "cannot load font", "path", fontPath, "err", err)
+		return nil, 0
+	}
+	return font, size
+}
+
+func maxInt(a, b int32) int32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func getTextDimensions(font *ttf.Font, text string) (int32, int32) {
+	if text == "" || font == nil {
+		return 0, 0
+	}
+
+	width, height, err := font.SizeUTF8(text)
+	if err != nil {
+		return 0, 0
+	}
+	return int32(width), int32(height)
+}
+
+func (e *Engine) renderMenu(element Element) {
+	renderer, config := e.renderer, e.config
+
+	bgColor := sdl.Color{R: 32, G: 32, B: 32, A: 200}
+	textColor := sdl.Color{R: 255, G: 255, B: 255, A: 255}
+
+	highlightColor := sdl.Color{R: 51, G: 51, B: 51, A: 255}
+	highlightBgColor := sdl.Color{R: 0, G: 123, B: 255, A: 255}
+
+	font, _ := e.getFontAndSize("small")
+	if font == nil {
+		return
+	}
+
+	screenWidth, _ := e.LogicalSize()
+
+	// Background bar
+	renderer.SetDrawColor(bgColor.R, bgColor.G, bgColor.B, bgColor.A)
+	renderer.FillRect(&sdl.Rect{X: 0, Y: element.Y, W: screenWidth, H: 50})
+
+	buttonX := int32(30)
+
+	for i, scene := range config.Scenes {
+		if scene.Modal {
+			continue // Opened with push_scene, not from the menu
+		}
+		isSelected := e.currentSceneIndex == i
+
+		btnColor := textColor
+		rectColor := highlightColor
+		if isSelected {
+			btnColor = textColor
+			rectColor = highlightBgColor
+		}
+
+		label := scene.Name
+		textWidth, textHeight := getTextDimensions(font, label)
+
+		// Calculate button dimensions with padding
+		padding := int32(15)
+		width := textWidth + padding*2
+		height := int32(40) // Fixed height for menu buttons
+
+		// Draw rounded rectangle as button background
+		drawRoundedRect(renderer, &sdl.Rect{
+			X: buttonX,
+			Y: element.Y + 5,
+			W: width,
+			H: height,
+		}, 20, rectColor)
+
+		// Draw label centered in the button
+		e.renderText(font, label, btnColor,
+			buttonX+(width-textWidth)/2,
+			element.Y+5+(height-textHeight)/2)
+
+		e.menuButtonRects[i] = sdl.Rect{
+			X: buttonX,
+			Y: element.Y + 5,
+			W: width,
+			H: height,
+		}
+		buttonX += width + 10
+	}
+
+	// Clock display (right side), redrawn when the minute changes
+	now := time.Now()
+	currentTime := now.Format("15:04")
+	e.redrawAt(now.Truncate(time.Minute).Add(time.Minute))
+	e.renderText(font, currentTime, textColor, screenWidth-70, element.Y+15)
+}
+
+func (e *Engine) renderText(font *ttf.Font, text string, color sdl.Color, x int32, y int32) (int32, int32) {
+	processedText := substituteVariables(text, e.config)
+	if processedText == "" || font == nil {
+		return 0, 0
+	}
+
+	texture, w, h, err := e.resources.Text(font, processedText, color)
+	if err != nil {
+		renderLog.Error("cannot render text", "text", processedText, "err", err)
+		return 0, 0
+	}
+
+	e.copyTexture(texture, &sdl.Rect{
+		X: x,
+		Y: y,
+		W: w,
+		H: h,
+	})
+
+	return w, h
+}
+
+func (e *Engine) renderButton(element Element) {
+	renderer, config := e.renderer, e.config
+
+	defaultTextColor := sdl.Color{R: 0, G: 0, B: 0, A: 255}     // Default to black
+	defaultBgColor := sdl.Color{R: 255, G: 255, B: 255, A: 255} // Default to white
+
+	color := resolveColor(config, element.Color, defaultTextColor)
+	bgColor := resolveColor(config, element.BgColor, defaultBgColor)
+
+	// Render button background
+	renderer.SetDrawColor(bgColor.R, bgColor.G, bgColor.B, bgColor.A)
+	renderer.FillRect(&sdl.Rect{X: element.X, Y: element.Y, W: 200, H: 50})
+
+	// Render button text
+	font, _ := e.getFontAndSize(element.Font)
+	e.renderText(font, element.Text, color, element.X+100, element.Y+25)
+}
+
+func (e *Engine) renderScene(sceneConfig SceneConfig) {
+	renderer, config := e.renderer, e.config
+
+	renderLog.Debug("rendering scene", "scene", sceneConfig.Name)
+	e.menuButtonRects = make(map[int]sdl.Rect) // Filled in again by a visible menu
+
+	for i, element := range sceneConfig.Elements {
+		if !e.visible(element) {
+			continue
+		}
+		renderLog.Debug("rendering element", "index", i, "type", element.Type, "text", element.Text)
+		defaultTextColor := sdl.Color{R: 0, G: 0, B: 0, A: 255}     // Default to black
+		defaultBgColor := sdl.Color{R: 255, G: 255, B: 255, A: 255} // Default to white
+
+		color := resolveColor(config, element.Color, defaultTextColor)
+		bgColor := resolveColor(config, element.BgColor, defaultBgColor)
+
+		// Highlight selected button by inverting colors
+		if (element.Type == "button" || element.Type == "dynamiclist") && i == e.selectedButtonIndex {
+			color, bgColor = bgColor, color // Invert colors
+		}
+
+		e.alpha = element.alpha()
+		if !e.enabled(element) {
+			e.alpha /= 2 // Dim disabled elements
+		}
+
+		font, _ := e.getFontAndSize(element.Font)
+
+		switch element.Type {
+		case "image":
+			if element.Image != "" {
+				// Handle width with variable substitution
+				widthStr := substituteVariables(string(element.Width), config)
+				width, err := strconv.Atoi(widthStr)
+				if err != nil {
+					width = 0
+				}
+
+				// Handle height with variable substitution
+				heightStr := substituteVariables(string(element.Height), config)
+				height, err := strconv.Atoi(heightStr)
+				if err != nil {
+					height = 0
+				}
+
+				imageTexture := e.loadTexture(substituteVariables(element.Image, config))
+				if imageTexture != nil {
+					imageRect := sdl.Rect{X: element.X, Y: element.Y, W: int32(width), H: int32(height)}
+					e.copyTexture(imageTexture, &imageRect)
+				}
+			}
+		case "input":
+			e.renderInputField(element)
+		case "video":
+			if video := substituteVariables(element.Video, config); !e.videoPlayed && !e.headless && video != "" {
+				widthStr := substituteVariables(string(element.Width), config)
+				width, err := strconv.Atoi(widthStr)
+				if err != nil {
+					width = 0
+				}
+
+				heightStr := substituteVariables(string(element.Height), config)
+				height, err := strconv.Atoi(heightStr)
+				if err != nil {
+					height = 0
+				}
+
+				// Construct the ffplay command with the correct parameters
+				cmd := exec.Command("ffmpeg/ffplay", video,
+					"-noborder",
+					"-x", strconv.Itoa(width),
+					"-y", strconv.Itoa(height),
+					"-left", strconv.Itoa(int(element.X)),
+					"-top", strconv.Itoa(int(element.Y)),
+					"-autoexit")
+
+				// Start the ffplay process
+				if err := e.pathAllowed(video); err != nil {
+					processLog.Error("cannot play video", "video", video, "err", err)
+				} else {
+					e.startProcess(cmd, func(result processResult) {
+						if result.err != nil {
+							processLog.Error("ffplay failed", "video", video, "err", result.err)
+						}
+					})
+				}
+				e.videoPlayed = true
+
+				/*if err := cmd.Wait(); err != nil {
+					processLog.Error("ffplay failed", "video", video, "err", err)
+				}*/
+			}
+		case "label": // Add specific label handling
+			if font != nil {
+				e.renderText(font, element.Text, color,
+					element.X,
+					element.Y)
+			}
+		case "collapsedlist":
+			if element.Command != "" && element.ListVariable != "" {
+				// Run the command in the background if it hasn't filled the list yet
+				if _, exists := config.Variables.Custom[element.ListVariable]; !exists {
+					e.loadCollapsedList(element)
+				}
+
+				// Render the collapsed list
+				if items, ok := config.Variables.Custom[element.ListVariable].([]CollapsedListItem); ok {
+					e.renderCollapsedList(element, items)
+				}
+			} else {
+				// Render placeholder if no command is set
+				e.renderText(font, "Collapsed List", color, element.X, element.Y)
+			}
+		// In the renderScene function, update the button rendering case:
+		case "button":
+			// Calculate dimensions
+			textWidth, textHeight := getTextDimensions(font, element.Text)
+			width := textWidth + 20
+			height := textHeight + 10
+
+			// Override dimensions if specified
+			if string(element.Width) != "" {
+				widthStr := substituteVariables(string(element.Width), config)
+				w, _ := strconv.Atoi(widthStr)
+				width = int32(w)
+			}
+			if string(element.Height) != "" {
+				heightStr := substituteVariables(string(element.Height), config)
+				h, _ := strconv.Atoi(heightStr)
+				height = int32(h)
+			}
+
+			// Render button background
+			e.setDrawColor(bgColor)
+			renderer.FillRect(&sdl.Rect{X: element.X, Y: element.Y, W: width, H: height})
+
+			// Render button text - centered properly
+			textX := element.X + (width-textWidth)/2
+			textY := element.Y + (height-textHeight)/2
+			e.renderText(font, element.Text, color, textX, textY)
+		case "dynamiclist":
+			e.renderDynamicList(i, element, color, bgColor)
+		case "console":
+			e.renderConsole(i, element, i == e.selectedButtonIndex)
+		case "menu":
+			e.renderMenu(element)
+		default:
+			warnLimited(renderLog, element.Type, "unknown element type", "type", element.Type)
+		}
+	}
+	e.alpha = 255
+}
+
+// alpha converts the element's opacity to an alpha value.
+func (element Element) alpha() uint8 {
+	if element.Opacity == nil || *element.Opacity >= 1 {
+		return 255
+	}
+	if *element.Opacity <= 0 {
+		return 0
+	}
+	return uint8(*element.Opacity * 255)
+}
+
+// setDrawColor sets the draw color faded by the current element's opacity.
+func (e *Engine) setDrawColor(c sdl.Color) {
+	e.renderer.SetDrawColor(c.R, c.G, c.B, uint8(uint32(c.A)*uint32(e.alpha)/255))
+}
+
+// copyTexture draws texture faded by the current element's opacity.
+// Textures made from images without an alpha channel don't blend by
+// default, which would ignore the opacity.
+func (e *Engine) copyTexture(texture *sdl.Texture, dst *sdl.Rect) {
+	texture.SetBlendMode(sdl.BLENDMODE_BLEND)
+	texture.SetAlphaMod(e.alpha)
+	e.renderer.Copy(texture, nil, dst)
+}
+
+func (e *Engine) renderCollapsedList(element Element, items []CollapsedListItem) {
+	renderer, config := e.renderer, e.config
+
+	// Render the list icon
+	font, _ := e.getFontAndSize(element.Font)
+	if font == nil {
+		return
+	}
+
+	// Draw list background
+	renderer.SetDrawColor(240, 240, 240, 255)
+	renderer.FillRect(&sdl.Rect{X: element.X, Y: element.Y, W: 300, H: 40})
+
+	// Draw list icon and text
+	e.renderText(font, "📋 Collapsed List",
+		resolveColor(config, element.Color, sdl.Color{R: 0, G: 0, B: 0, A: 255}),
+		element.X+10, element.Y+20)
+
+	for i, item := range items {
+		yPos := element.Y + 40 + int32(i)*60
+		e.renderCollapsedListItem(item, element.X, yPos, 300, 60)
+	}
+
+}
+
+func drawRoundedRect(renderer *sdl.Renderer, rect *sdl.Rect, radius int32, color sdl.Color) {
+	renderer.SetDrawColor(color.R, color.G, color.B, color.A)
+
+	// Draw the main rectangle (excluding corners)
+	renderer.FillRect(&sdl.Rect{
+		X: rect.X + radius,
+		Y: rect.Y,
+		W: rect.W - 2*radius,
+		H: rect.H,
+	})
+	renderer.FillRect(&sdl.Rect{
+		X: rect.X,
+		Y: rect.Y + radius,
+		W: rect.W,
+		H: rect.H - 2*radius,
+	})
+
+	// Draw the rounded corners
+	drawFilledCircle(renderer, rect.X+radius, rect.Y+radius, radius, color)
+	drawFilledCircle(renderer, rect.X+rect.W-radius, rect.Y+radius, radius, color)
+	drawFilledCircle(renderer, rect.X+radius, rect.Y+rect.H-radius, radius, color)
+	drawFilledCircle(renderer, rect.X+rect.W-radius, rect.Y+rect.H-radius, radius, color)
+}
+
+func (e *Engine) renderCollapsedListItem(item CollapsedListItem, x, y, width, height int32) {
+	renderer := e.renderer
+
+	// Draw item background
+	renderer.SetDrawColor(255, 255, 255, 255)
+	renderer.FillRect(&sdl.Rect{X: x, Y: y, W: width, H: height})
+
+	// Draw item image if available
+	if item.Image != "" {
+		if texture := e.loadTexture(item.Image); texture != nil {
+			renderer.Copy(texture, nil, &sdl.Rect{X: x + 5, Y: y + 5, W: 50, H: 50})
+		}
+	}
+
+	// Draw item text
+	font, _ := e.getFontAndSize("small")
+	if font != nil {
+		e.renderText(font, item.Title,
+			sdl.Color{R: 0, G: 0, B: 0, A: 255},
+			x+60, y+10)
+
+		if item.Description != "" {
+			e.renderText(font, item.Description,
+				sdl.Color{R: 100, G: 100, B: 100, A: 255},
+				x+60, y+30)
+		}
+	}
+}
+
+func (e *Engine) renderInputField(element Element) {
+	renderer, config := e.renderer, e.config
+
+	// Draw background
+	bgColor := resolveColor(config, element.BgColor, sdl.Color{R: 255, G: 255, B: 255, A: 255})
+	e.setDrawColor(bgColor)
+	renderer.FillRect(&sdl.Rect{
+		X: element.X,
+		Y: element.Y,
+		W: 300, // Default width
+		H: 40,  // Default height
+	})
+
+	// Draw text
+	textColor := resolveColor(config, element.Color, sdl.Color{R: 0, G: 0, B: 0, A: 255})
+	font, _ := e.getFontAndSize(element.Font)
+
+	// Show cursor if active
+	if e.inputActiveElement != nil {
+		e.redrawAt(time.Now().Truncate(500 * time.Millisecond).Add(500 * time.Millisecond))
+	}
+	text := e.inputTextBuffer
+	if e.inputActiveElement == &element && (uint32(sdl.GetTicks64()/500)%2 == 0) {
+		text += "_"
+	}
+
+	e.renderText(font, text, textColor, element.X+10, element.Y+20)
+
+	// Draw border if active
+	if e.inputActiveElement == &element {
+		renderer.SetDrawColor(0, 120, 215, 255)
+		renderer.DrawRect(&sdl.Rect{
+			X: element.X - 2,
+			Y: element.Y - 2,
+			W: 304,
+			H: 44,
+		})
+	}
+}
+
+func (e *Engine) renderImage(element Element) {
+	if element.Image == "" {
+		return
+	}
+	texture := e.loadTexture(substituteVariables(element.Image, e.config))
+	if texture == nil {
+		return
+	}
+
+	widthStr := substituteVariables(string(element.Width), e.config)
+	width, _ := strconv.Atoi(widthStr)
+	heightStr := substituteVariables(string(element.Height), e.config)
+	height, _ := strconv.Atoi(heightStr)
+
+	if width == 0 || height == 0 {
+		_, _, w, h, _ := texture.Query()
+		width = int(w)
+		height = int(h)
+	}
+	e.copyTexture(texture, &sdl.Rect{X: element.X, Y: element.Y, W: int32(width), H: int32(height)})
+}
+
+func drawFilledCircle(renderer *sdl.Renderer, x, y, r int32, color sdl.Color) {
+	renderer.SetDrawColor(color.R, color.G, color.B, color.A)
+	for w := int32(0); w < r*2; w++ {
+		for h := int32(0); h < r*2; h++ {
+			dx := r - w
+			dy := r - h
+			if dx*dx+dy*dy <= r*r {
+				renderer.DrawPoint(x+dx, y+dy)
+			}
+		}
+	}
+}
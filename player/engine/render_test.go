@@ -0,0 +1,157 @@
+package engine
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden images in testdata/golden")
+
+// Golden images are compared loosely: SDL and FreeType builds round
+// blending and antialiasing a little differently. A channel may be off by
+// goldenTolerance, and up to goldenMaxOff of the pixels by more.
+const (
+	goldenTolerance = 8
+	goldenMaxOff    = 0.005
+)
+
+// Scenes are rendered at this size, the resolution of the test configs.
+const goldenWidth, goldenHeight = 320, 240
+
+func TestRenderGolden(t *testing.T) {
+	if err := InitHeadless(); err != nil {
+		t.Skip("no SDL:", err)
+	}
+	defer Quit()
+
+	tests := []struct {
+		name   string // Of the golden image
+		config string // In testdata/render
+		scene  string
+		push   string          // Scene pushed over it, if any
+		ignore image.Rectangle // Changes from run to run
+	}{
+		{"empty", "scenes.json", "empty", "", image.Rectangle{}},
+		{"buttons", "scenes.json", "buttons", "", image.Rectangle{}},
+		{"label", "scenes.json", "label", "", image.Rectangle{}},
+		{"input", "scenes.json", "input", "", image.Rectangle{}},
+		{"image", "scenes.json", "image", "", image.Rectangle{}},
+		{"opacity", "scenes.json", "opacity", "", image.Rectangle{}},
+		{"dynamiclist", "scenes.json", "dynamiclist", "", image.Rectangle{}},
+		{"console", "scenes.json", "console", "", image.Rectangle{}},
+		{"conditions", "scenes.json", "conditions", "", image.Rectangle{}},
+		{"modal", "scenes.json", "buttons", "dialog", image.Rectangle{}},
+		{"menu", "menu.json", "Games", "", image.Rect(240, 190, 320, 240)}, // The clock
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := LoadConfig(filepath.Join("testdata", "render", tt.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+			backend, err := NewOffscreenBackend(goldenWidth, goldenHeight)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e := New(config)
+			e.OpenBackend(backend)
+			defer e.Close()
+
+			if !e.ChangeScene(tt.scene) {
+				t.Fatalf("no scene %q", tt.scene)
+			}
+			if tt.push != "" && !e.PushScene(tt.push) {
+				t.Fatalf("no scene %q", tt.push)
+			}
+			e.Render()
+			settle(t, e)
+			e.Render()
+			got := backend.Image()
+			draw.Draw(got, tt.ignore, image.Black, image.Point{}, draw.Src)
+			checkGolden(t, filepath.Join("testdata", "golden", tt.name+".png"), got)
+		})
+	}
+}
+
+// settle waits for the commands the first frame started and runs what they
+// hand back to the main loop, so the next frame shows their output.
+func settle(t *testing.T, e *Engine) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		e.runPending()
+		busy := false
+		for _, list := range e.dynamicLists {
+			busy = busy || list.loading
+		}
+		for _, c := range e.consoles {
+			busy = busy || c.running
+		}
+		if !busy {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("commands still running")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// checkGolden compares got with the image at path, or replaces that image
+// with -update.
+func checkGolden(t *testing.T, path string, got *image.RGBA) {
+	t.Helper()
+	if *update {
+		file, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		if err := png.Encode(file, got); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	defer file.Close()
+	want, err := png.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want.Bounds() != got.Bounds() {
+		t.Fatalf("size is %v, want %v", got.Bounds().Size(), want.Bounds().Size())
+	}
+
+	off := 0
+	var first string
+	for y := got.Rect.Min.Y; y < got.Rect.Max.Y; y++ {
+		for x := got.Rect.Min.X; x < got.Rect.Max.X; x++ {
+			r, g, b, a := want.At(x, y).RGBA()
+			w := [4]int{int(r >> 8), int(g >> 8), int(b >> 8), int(a >> 8)}
+			p := got.RGBAAt(x, y)
+			for i, c := range [4]uint8{p.R, p.G, p.B, p.A} {
+				if d := int(c) - w[i]; d > goldenTolerance || d < -goldenTolerance {
+					if off == 0 {
+						first = fmt.Sprintf("(%d, %d) is %v, want %v", x, y, p, w)
+					}
+					off++
+					break
+				}
+			}
+		}
+	}
+	if allowed := int(goldenMaxOff * float64(got.Rect.Dx()*got.Rect.Dy())); off > allowed {
+		t.Errorf("%d pixels differ from %s, first %s (run with -update if the change is intended)", off, path, first)
+	}
+}
@@ -0,0 +1,242 @@
+package engine
+
+import (
+	"container/list"
+	"fmt"
+	"runtime"
+
+	"github.com/veandco/go-sdl2/img"
+	"github.com/veandco/go-sdl2/sdl"
+	"github.com/veandco/go-sdl2/ttf"
+)
+
+// Cache sizes. Fonts are few and expensive to open; rendered text changes
+// often (the menu clock, input fields) so it gets the most room.
+const (
+	textureCacheSize = 64
+	fontCacheSize    = 32
+	textCacheSize    = 256
+)
+
+// lruCache is a fixed-size map that drops the least recently used entry
+// when it is full. evict is called for every value that leaves the cache.
+type lruCache[V any] struct {
+	capacity int
+	order    *list.List
+	items    map[string]*list.Element
+	evict    func(V)
+}
+
+type lruEntry[V any] struct {
+	key   string
+	value V
+}
+
+func newLRUCache[V any](capacity int, evict func(V)) *lruCache[V] {
+	return &lruCache[V]{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+		evict:    evict,
+	}
+}
+
+func (c *lruCache[V]) get(key string) (V, bool) {
+	if el, ok := c.items[key]; ok {
+		c.order.MoveToFront(el)
+		return el.Value.(*lruEntry[V]).value, true
+	}
+	var zero V
+	return zero, false
+}
+
+func (c *lruCache[V]) add(key string, value V) {
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+	c.items[key] = c.order.PushFront(&lruEntry[V]{key: key, value: value})
+	for c.order.Len() > c.capacity {
+		c.removeElement(c.order.Back())
+	}
+}
+
+func (c *lruCache[V]) remove(key string) {
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+func (c *lruCache[V]) removeElement(el *list.Element) {
+	entry := el.Value.(*lruEntry[V])
+	c.order.Remove(el)
+	delete(c.items, entry.key)
+	c.evict(entry.value)
+}
+
+func (c *lruCache[V]) clear() {
+	for c.order.Len() > 0 {
+		c.removeElement(c.order.Back())
+	}
+}
+
+// textTexture is a rendered string together with its size.
+type textTexture struct {
+	texture *sdl.Texture
+	w, h    int32
+}
+
+// Resources caches textures, fonts and rendered text for the renderer they
+// were created with. Textures and text are dropped whenever the scene
+// changes; fonts live until the engine is closed.
+type Resources struct {
+	renderer *sdl.Renderer
+	textures *lruCache[*sdl.Texture]
+	fonts    *lruCache[*ttf.Font]
+	texts    *lruCache[*textTexture]
+	fontKeys map[*ttf.Font]string
+
+	allow func(path string) error // Vets files before they are opened; nil allows all
+}
+
+func newResources(renderer *sdl.Renderer) *Resources {
+	r := &Resources{
+		renderer: renderer,
+		fontKeys: make(map[*ttf.Font]string),
+	}
+	r.textures = newLRUCache(textureCacheSize, func(t *sdl.Texture) { t.Destroy() })
+	r.texts = newLRUCache(textCacheSize, func(t *textTexture) { t.texture.Destroy() })
+	r.fonts = newLRUCache(fontCacheSize, func(f *ttf.Font) {
+		// Rendered text is keyed by font; drop it before the font goes away
+		// so a new font at the same address can't pick it up.
+		r.texts.clear()
+		delete(r.fontKeys, f)
+		f.Close()
+	})
+	return r
+}
+
+// Texture returns the image at path, loading it on first use. path may
+// also be a data: URL or a bare base64 image, which is decoded in memory.
+func (r *Resources) Texture(path string) (*sdl.Texture, error) {
+	if texture, ok := r.textures.get(path); ok {
+		return texture, nil
+	}
+	texture, err := r.loadTexture(path)
+	if err != nil {
+		return nil, err
+	}
+	r.textures.add(path, texture)
+	return texture, nil
+}
+
+func (r *Resources) loadTexture(path string) (*sdl.Texture, error) {
+	data, inline, err := decodeInlineImage(path)
+	if err != nil {
+		return nil, err
+	}
+	if !inline {
+		if err := r.allowed(path); err != nil {
+			return nil, err
+		}
+		return img.LoadTexture(r.renderer, path)
+	}
+	rw, err := sdl.RWFromMem(data)
+	if err != nil {
+		return nil, err
+	}
+	// rw points into data, which has to stay alive until SDL is done.
+	texture, err := img.LoadTextureRW(r.renderer, rw, true)
+	runtime.KeepAlive(data)
+	return texture, err
+}
+
+func (r *Resources) allowed(path string) error {
+	if r.allow == nil {
+		return nil
+	}
+	return r.allow(path)
+}
+
+// Font returns the font at path in the given point size, opening it on
+// first use. The font is owned by the cache and must not be closed.
+func (r *Resources) Font(path string, size int) (*ttf.Font, error) {
+	key := fmt.Sprintf("%s@%d", path, size)
+	if font, ok := r.fonts.get(key); ok {
+		return font, nil
+	}
+	if err := r.allowed(path); err != nil {
+		return nil, err
+	}
+	font, err := ttf.OpenFont(path, size)
+	if err != nil {
+		return nil, err
+	}
+	r.fonts.add(key, font)
+	r.fontKeys[font] = key
+	return font, nil
+}
+
+// Text returns text rendered with font and color, rendering it on first use.
+func (r *Resources) Text(font *ttf.Font, text string, color sdl.Color) (*sdl.Texture, int32, int32, error) {
+	fontKey, ok := r.fontKeys[font]
+	if !ok {
+		fontKey = fmt.Sprintf("%p", font)
+	}
+	key := fmt.Sprintf("%s|%d,%d,%d,%d|%s", fontKey, color.R, color.G, color.B, color.A, text)
+	if t, ok := r.texts.get(key); ok {
+		return t.texture, t.w, t.h, nil
+	}
+
+	surface, err := font.RenderUTF8Blended(text, color)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer surface.Free()
+
+	texture, err := r.renderer.CreateTextureFromSurface(surface)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	t := &textTexture{texture: texture, w: surface.W, h: surface.H}
+	r.texts.add(key, t)
+	return t.texture, t.w, t.h, nil
+}
+
+// Invalidate drops the cached texture for path, so the next Texture call
+// reads it from disk again.
+func (r *Resources) Invalidate(path string) {
+	r.textures.remove(path)
+}
+
+// Clear drops all cached textures and rendered text.
+func (r *Resources) Clear() {
+	r.textures.clear()
+	r.texts.clear()
+}
+
+// Destroy releases everything, fonts included.
+func (r *Resources) Destroy() {
+	r.Clear()
+	r.fonts.clear()
+}
+
+// Resources returns the engine's resource cache. It is nil until Open or
+// OpenBackend has been called.
+func (e *Engine) Resources() *Resources { return e.resources }
+
+func (e *Engine) loadTexture(path string) *sdl.Texture {
+	texture, err := e.resources.Texture(path)
+	if err != nil {
+		warnLimited(renderLog, path, "cannot load image", "path", shortPath(path), "err", err)
+		return nil
+	}
+	return texture
+}
+
+// shortPath trims inline image data so it can be logged.
+func shortPath(path string) string {
+	if len(path) > 64 {
+		return path[:61] + "..."
+	}
+	return path
+}
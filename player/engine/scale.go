@@ -0,0 +1,61 @@
+package engine
+
+import "github.com/veandco/go-sdl2/sdl"
+
+// windowSize picks the initial window size: the logical size, shrunk to the
+// display if the display is smaller (as on the TrimUI Brick), so the window
+// always fits on screen.
+func windowSize(logicalW, logicalH int32) (int32, int32) {
+	mode, err := sdl.GetCurrentDisplayMode(0)
+	if err != nil {
+		renderLog.Warn("cannot detect display mode", "err", err)
+		return logicalW, logicalH
+	}
+	w, h := logicalW, logicalH
+	if mode.W > 0 && mode.W < w {
+		w = mode.W
+	}
+	if mode.H > 0 && mode.H < h {
+		h = mode.H
+	}
+	return w, h
+}
+
+// applyScaling maps the logical coordinate space onto the backend's real
+// output size. SDL translates mouse coordinates back to logical ones.
+func (e *Engine) applyScaling() {
+	renderer := e.renderer
+	logicalW, logicalH := e.config.LogicalSize()
+	outW, outH := e.backend.Size()
+
+	switch e.config.Resolution.Scale {
+	case ScaleStretch:
+		renderer.SetLogicalSize(0, 0)
+		renderer.SetIntegerScale(false)
+		renderer.SetScale(float32(outW)/float32(logicalW), float32(outH)/float32(logicalH))
+	case ScaleInteger:
+		renderer.SetIntegerScale(true)
+		renderer.SetLogicalSize(logicalW, logicalH)
+	default:
+		renderer.SetIntegerScale(false)
+		renderer.SetLogicalSize(logicalW, logicalH)
+	}
+}
+
+// SetFullscreen chooses between a desktop-sized fullscreen window and a
+// normal one. It must be called before Open.
+func (e *Engine) SetFullscreen(fullscreen bool) {
+	e.fullscreen = fullscreen
+}
+
+// SetWindowSize sets the size of the window Open creates. Scenes are still
+// drawn in the config's resolution and scaled to fit. Zero restores the
+// default, the resolution shrunk to the display.
+func (e *Engine) SetWindowSize(width, height int32) {
+	e.windowWidth, e.windowHeight = width, height
+}
+
+// LogicalSize returns the size of the coordinate space scenes are drawn in.
+func (e *Engine) LogicalSize() (int32, int32) {
+	return e.config.LogicalSize()
+}
@@ -0,0 +1,208 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// maxSceneRedirects stops onEnter actions that change scene from bouncing
+// between scenes forever.
+const maxSceneRedirects = 8
+
+// maxSceneHistory caps the scene stack; the oldest entries are dropped.
+const maxSceneHistory = 32
+
+// sceneEntry is a scene on the stack together with the element that was
+// focused when the scene above it was pushed.
+type sceneEntry struct {
+	index    int
+	selected int
+}
+
+// enterScene makes the scene at index active and focuses its first
+// selectable element. Once the engine has started, the old scene's onExit
+// and the new scene's onEnter actions run around the switch. Every way of
+// changing scenes goes through here or switchScene.
+func (e *Engine) enterScene(index int) {
+	e.switchScene(index, true, true)
+}
+
+// switchScene is enterScene with a choice of hooks. Opening a modal scene
+// doesn't exit the scene underneath, and closing it doesn't enter that
+// scene again; the scene underneath keeps its state meanwhile. It returns
+// false if the change was refused.
+func (e *Engine) switchScene(index int, exitOld, enterNew bool) bool {
+	from := e.currentSceneIndex
+	if index == from {
+		e.selectedButtonIndex = e.findFirstSelectableElement(e.config.Scenes[index])
+		return true
+	}
+
+	if e.started {
+		if e.leavingScene {
+			triggerLog.Warn("ignoring scene change from an onExit action", "scene", e.config.Scenes[from].Name)
+			return false
+		}
+		if e.sceneRedirects >= maxSceneRedirects {
+			triggerLog.Warn("too many scene changes from onEnter actions", "scene", e.config.Scenes[index].Name)
+			return false
+		}
+		if exitOld {
+			e.leavingScene = true
+			e.runActions(Element{}, e.config.Scenes[from].OnExit)
+			e.leavingScene = false
+		}
+	}
+
+	e.currentSceneIndex = index
+	e.selectedButtonIndex = e.findFirstSelectableElement(e.config.Scenes[index])
+	e.videoPlayed = false
+	if exitOld && enterNew {
+		e.clearSceneState()
+	}
+	e.notifySceneChange(from)
+
+	if e.started {
+		if !enterNew {
+			e.resetSceneTimers(time.Now())
+			return true
+		}
+		e.sceneRedirects++
+		e.startScene()
+		e.sceneRedirects--
+	}
+	return true
+}
+
+// pushScene remembers the active scene and its focus and switches to the
+// scene at index. A modal scene opens over the active one.
+func (e *Engine) pushScene(index int) {
+	if index == e.currentSceneIndex {
+		return
+	}
+	if len(e.sceneStack) >= maxSceneHistory {
+		e.sceneStack = e.sceneStack[1:]
+	}
+	// Pushed first, so the new scene's onEnter actions can pop it.
+	e.sceneStack = append(e.sceneStack, sceneEntry{e.currentSceneIndex, e.selectedButtonIndex})
+	modal := e.config.Scenes[index].Modal
+	if !e.switchScene(index, !modal, true) {
+		e.sceneStack = e.sceneStack[:len(e.sceneStack)-1]
+	}
+}
+
+// popScene goes back to the scene below the active one on the stack and
+// focuses the element that was focused there. It returns false if the stack
+// is empty.
+func (e *Engine) popScene() bool {
+	if len(e.sceneStack) == 0 {
+		return false
+	}
+	entry := e.sceneStack[len(e.sceneStack)-1]
+	e.sceneStack = e.sceneStack[:len(e.sceneStack)-1]
+	modal := e.Scene().Modal
+	if !e.switchScene(entry.index, true, !modal) {
+		e.sceneStack = append(e.sceneStack, entry)
+		return false
+	}
+	if e.currentSceneIndex != entry.index {
+		return true // onEnter went somewhere else
+	}
+	elements := e.Scene().Elements
+	if entry.selected >= 0 && entry.selected < len(elements) && e.focusable(elements[entry.selected]) {
+		e.selectedButtonIndex = entry.selected
+	}
+	return true
+}
+
+// scenesBelow returns the stack entries a modal scene is drawn over, bottom
+// first: everything up to and including the first scene that isn't modal.
+func (e *Engine) scenesBelow() []sceneEntry {
+	first := len(e.sceneStack)
+	for modal := e.Scene().Modal; modal && first > 0; {
+		first--
+		modal = e.config.Scenes[e.sceneStack[first].index].Modal
+	}
+	return e.sceneStack[first:]
+}
+
+// renderActiveScene draws the background and the active scene. The scenes
+// a modal scene was pushed over are drawn first, dimmed, with the focus
+// they had.
+func (e *Engine) renderActiveScene() {
+	e.renderBackground()
+
+	if below := e.scenesBelow(); len(below) > 0 {
+		// Elements with state, like dynamic lists, find it by scene index.
+		current, selected := e.currentSceneIndex, e.selectedButtonIndex
+		for _, entry := range below {
+			e.currentSceneIndex, e.selectedButtonIndex = entry.index, entry.selected
+			e.renderScene(e.config.Scenes[entry.index])
+		}
+		e.currentSceneIndex, e.selectedButtonIndex = current, selected
+
+		screenWidth, screenHeight := e.LogicalSize()
+		e.renderer.SetDrawColor(0, 0, 0, 128)
+		e.renderer.FillRect(&sdl.Rect{X: 0, Y: 0, W: screenWidth, H: screenHeight})
+	}
+	e.renderScene(e.Scene())
+}
+
+// startScene runs the active scene's onEnter actions and starts its timers.
+func (e *Engine) startScene() {
+	e.resetSceneTimers(time.Now())
+	e.runActions(Element{}, e.Scene().OnEnter)
+}
+
+// interval returns how often the timer fires, or 0 if it never does.
+func (t SceneTimer) interval() time.Duration {
+	if t.Interval <= 0 {
+		return 0
+	}
+	return time.Duration(t.Interval) * time.Millisecond
+}
+
+// resetSceneTimers schedules the active scene's timers one interval from
+// now.
+func (e *Engine) resetSceneTimers(now time.Time) {
+	timers := e.Scene().OnTimer
+	e.sceneTimers = make([]time.Time, len(timers))
+	e.timerRuns = make([]*actionRun, len(timers))
+	for i, timer := range timers {
+		if interval := timer.interval(); interval > 0 {
+			e.sceneTimers[i] = now.Add(interval)
+		}
+	}
+}
+
+// runSceneTimers runs the actions of every timer that is due at now. A
+// timer whose previous run is still waiting on a step is skipped.
+func (e *Engine) runSceneTimers(now time.Time) {
+	scene := e.currentSceneIndex
+	for i, timer := range e.Scene().OnTimer {
+		if i >= len(e.sceneTimers) || e.sceneTimers[i].IsZero() || now.Before(e.sceneTimers[i]) {
+			continue
+		}
+		e.sceneTimers[i] = now.Add(timer.interval())
+		if run := e.timerRuns[i]; run != nil && !run.done {
+			continue
+		}
+		e.frames.dirty.Store(true)
+		e.timerRuns[i] = e.runActions(Element{}, timer.Actions)
+		if e.currentSceneIndex != scene {
+			return // The new scene has timers of its own
+		}
+	}
+}
+
+// nextSceneTimer returns when the next timer is due, or the zero time.
+func (e *Engine) nextSceneTimer() time.Time {
+	var next time.Time
+	for _, t := range e.sceneTimers {
+		if !t.IsZero() && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+	return next
+}
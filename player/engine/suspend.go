@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"os/exec"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// handoffSubsystems are the SDL subsystems shut down while another program
+// has the screen. Closing video releases a KMS/DRM display, which merely
+// hiding the window would not.
+const handoffSubsystems = sdl.INIT_VIDEO | sdl.INIT_JOYSTICK | sdl.INIT_GAMECONTROLLER
+
+// runForeground runs cmd in place of the player: the window, the renderer
+// and the controller are released while it runs and reopened when it
+// exits. Then the exit code is stored and the onReturn actions run before
+// resume is called. It returns false if cmd could not be started or is not
+// allowed.
+func (e *Engine) runForeground(cmd *exec.Cmd, element Element, resume func()) bool {
+	if err := e.commandAllowed(cmd); err != nil {
+		processLog.Error("cannot start external app", "command", cmd.Path, "err", err)
+		return false
+	}
+	restore := e.suspend()
+	if err := cmd.Start(); err != nil {
+		restore()
+		processLog.Error("cannot start external app", "command", cmd.Path, "err", err)
+		return false
+	}
+	processLog.Info("handing the screen to external app", "command", cmd.Path)
+	cmd.Wait()
+	restore()
+
+	e.appReturned(element, cmd.ProcessState.ExitCode(), resume)
+	return true
+}
+
+// suspend saves persistent variables and releases everything another
+// program could fight the player for. The returned function takes it all
+// back. Headless engines keep their backend, as nothing else can use it.
+func (e *Engine) suspend() (restore func()) {
+	e.saveState() // In case the player doesn't come back
+
+	if e.headless || e.backend == nil {
+		return func() {}
+	}
+	if e.controller != nil {
+		e.controller.Close()
+		e.controller = nil
+	}
+	e.resources.Destroy()
+	e.resources = nil
+	e.backend.Destroy()
+	e.backend, e.renderer = nil, nil
+	sdl.QuitSubSystem(handoffSubsystems)
+
+	return func() {
+		if err := sdl.InitSubSystem(handoffSubsystems); err != nil {
+			renderLog.Error("cannot reinitialize SDL", "err", err)
+			e.Stop()
+			return
+		}
+		if err := e.Open(); err != nil {
+			renderLog.Error("cannot reopen the window", "err", err)
+			e.Stop()
+			return
+		}
+		// Input meant for the other program is still queued.
+		sdl.FlushEvents(sdl.FIRSTEVENT, sdl.LASTEVENT)
+		e.Invalidate()
+	}
+}
+
+// appReturned stores an external app's exit code in its return variable
+// and runs its onReturn actions, then calls resume.
+func (e *Engine) appReturned(element Element, code int, resume func()) {
+	if element.ExternalAppReturn != "" {
+		e.setVariable(element.ExternalAppReturn, code)
+	}
+	r := &actionRun{e: e, element: element, actions: element.OnReturn, after: resume}
+	r.resume()
+}
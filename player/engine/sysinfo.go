@@ -0,0 +1,347 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// systemVariables are the names SysfsInfo fills in. Custom variables of
+// the same name take precedence.
+var systemVariables = []string{
+	"battery", "charging", "time", "date", "ip", "wifiSsid",
+	"storageFree", "storageFreeBytes", "hostname", "volume", "brightness",
+}
+
+// Defaults for SystemInfoConfig.
+const (
+	defaultSystemInterval = 5 * time.Second
+	defaultTimeFormat     = "15:04"
+	defaultDateFormat     = "2006-01-02"
+)
+
+// commandTimeout bounds the helper programs SysfsInfo runs.
+const commandTimeout = 2 * time.Second
+
+// SystemInfoConfig controls the built-in system variables.
+type SystemInfoConfig struct {
+	Interval   int    `json:"interval"`   // Milliseconds between refreshes, 0 for 5000, negative to turn them off
+	Root       string `json:"root"`       // Directory standing in for / when reading /sys and /proc
+	Storage    string `json:"storage"`    // Filesystem $storageFree reports on, "/" if empty
+	TimeFormat string `json:"timeFormat"` // Go time layout for $time
+	DateFormat string `json:"dateFormat"` // Go time layout for $date
+}
+
+func (c SystemInfoConfig) interval() time.Duration {
+	if c.Interval == 0 {
+		return defaultSystemInterval
+	}
+	if c.Interval < 0 {
+		return 0
+	}
+	return time.Duration(c.Interval) * time.Millisecond
+}
+
+// SystemInfo supplies the values of the system variables. Read runs on a
+// goroutine of its own and may block; values it leaves out are missing.
+type SystemInfo interface {
+	Read() map[string]interface{}
+}
+
+// SysfsInfo is the SystemInfo the player uses. It reads the battery from
+// /sys/class/power_supply, the backlight from /sys/class/backlight, network
+// interfaces from /sys/class/net and the hostname from /proc, all below
+// Root, so a fake tree can stand in for the real one.
+type SysfsInfo struct {
+	Root       string
+	Storage    string
+	TimeFormat string
+	DateFormat string
+
+	// Programs whose output gives the volume ("[75%]") and the Wi-Fi
+	// network's name. Nil skips the variable. The player leaves them nil
+	// unless the config uses $volume or $wifiSsid.
+	VolumeCommand []string
+	SSIDCommand   []string
+
+	// InterfaceAddrs lists an interface's addresses; nil uses the net
+	// package.
+	InterfaceAddrs func(name string) ([]net.Addr, error)
+
+	// Now returns the current time; nil uses time.Now.
+	Now func() time.Time
+}
+
+// NewSysfsInfo returns a SysfsInfo set up from config.
+func NewSysfsInfo(config SystemInfoConfig) *SysfsInfo {
+	return &SysfsInfo{
+		Root:          config.Root,
+		Storage:       config.Storage,
+		TimeFormat:    config.TimeFormat,
+		DateFormat:    config.DateFormat,
+		VolumeCommand: []string{"amixer", "get", "Master"},
+		SSIDCommand:   []string{"iwgetid", "-r"},
+	}
+}
+
+// Read implements SystemInfo.
+func (s *SysfsInfo) Read() map[string]interface{} {
+	values := make(map[string]interface{})
+
+	now := time.Now()
+	if s.Now != nil {
+		now = s.Now()
+	}
+	values["time"] = now.Format(orDefault(s.TimeFormat, defaultTimeFormat))
+	values["date"] = now.Format(orDefault(s.DateFormat, defaultDateFormat))
+
+	if capacity, charging, ok := s.battery(); ok {
+		values["battery"] = capacity
+		values["charging"] = charging
+	}
+	if brightness, ok := s.brightness(); ok {
+		values["brightness"] = brightness
+	}
+	if hostname, err := os.ReadFile(s.path("/proc/sys/kernel/hostname")); err == nil {
+		values["hostname"] = strings.TrimSpace(string(hostname))
+	}
+	values["ip"] = s.ip()
+	if s.SSIDCommand != nil {
+		ssid, _ := runCommand(s.SSIDCommand)
+		values["wifiSsid"] = strings.TrimSpace(ssid)
+	}
+	if s.VolumeCommand != nil {
+		if out, err := runCommand(s.VolumeCommand); err == nil {
+			if m := volumePattern.FindStringSubmatch(out); m != nil {
+				values["volume"], _ = strconv.Atoi(m[1])
+			}
+		}
+	}
+	if free, ok := freeSpace(orDefault(s.Storage, "/")); ok {
+		values["storageFree"] = formatBytes(free)
+		values["storageFreeBytes"] = float64(free)
+	}
+	return values
+}
+
+// volumePattern finds the level in amixer's output.
+var volumePattern = regexp.MustCompile(`\[(\d+)%\]`)
+
+func (s *SysfsInfo) path(p string) string {
+	return filepath.Join(s.Root, filepath.FromSlash(p))
+}
+
+// readInt reads a sysfs file holding a single number.
+func readInt(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// battery reports the first battery's charge in percent and whether it is
+// charging.
+func (s *SysfsInfo) battery() (int, bool, bool) {
+	dir := s.path("/sys/class/power_supply")
+	for _, name := range sortedEntries(dir) {
+		supply := filepath.Join(dir, name)
+		kind, err := os.ReadFile(filepath.Join(supply, "type"))
+		if err != nil || strings.TrimSpace(string(kind)) != "Battery" {
+			continue
+		}
+		capacity, err := readInt(filepath.Join(supply, "capacity"))
+		if err != nil {
+			continue
+		}
+		status, _ := os.ReadFile(filepath.Join(supply, "status"))
+		return capacity, strings.TrimSpace(string(status)) == "Charging", true
+	}
+	return 0, false, false
+}
+
+// brightness reports the first backlight's level in percent.
+func (s *SysfsInfo) brightness() (int, bool) {
+	dir := s.path("/sys/class/backlight")
+	for _, name := range sortedEntries(dir) {
+		level, err := readInt(filepath.Join(dir, name, "brightness"))
+		if err != nil {
+			continue
+		}
+		max, err := readInt(filepath.Join(dir, name, "max_brightness"))
+		if err != nil || max <= 0 {
+			continue
+		}
+		return (level*100 + max/2) / max, true
+	}
+	return 0, false
+}
+
+// ip returns the first IPv4 address of an interface that is up, or "" when
+// the device is offline. Loopback is skipped.
+func (s *SysfsInfo) ip() string {
+	addrs := s.InterfaceAddrs
+	if addrs == nil {
+		addrs = func(name string) ([]net.Addr, error) {
+			iface, err := net.InterfaceByName(name)
+			if err != nil {
+				return nil, err
+			}
+			return iface.Addrs()
+		}
+	}
+
+	dir := s.path("/sys/class/net")
+	for _, name := range sortedEntries(dir) {
+		if name == "lo" {
+			continue
+		}
+		state, err := os.ReadFile(filepath.Join(dir, name, "operstate"))
+		if err != nil || strings.TrimSpace(string(state)) != "up" {
+			continue
+		}
+		list, err := addrs(name)
+		if err != nil {
+			continue
+		}
+		for _, addr := range list {
+			if n, ok := addr.(*net.IPNet); ok && n.IP.To4() != nil {
+				return n.IP.String()
+			}
+		}
+	}
+	return ""
+}
+
+// sortedEntries lists dir's entries by name, or nothing if it can't be
+// read.
+func sortedEntries(dir string) []string {
+	entries, _ := os.ReadDir(dir)
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+	}
+	return names
+}
+
+func runCommand(args []string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, args[0], args[1:]...).Output()
+	return string(out), err
+}
+
+// formatBytes writes n the way file managers do, like "12.4 GB".
+func formatBytes(n uint64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// SetSystemInfo replaces the source of the system variables. Call it before
+// Run; nil goes back to reading the system as the config says.
+func (e *Engine) SetSystemInfo(info SystemInfo) {
+	e.systemInfo = info
+	if e.started {
+		e.startSystemInfo()
+	}
+}
+
+// startSystemInfo reads the system variables and keeps refreshing them
+// until Close. It restarts a refresh loop that is already running. Reads
+// happen on a goroutine, the first one too, so the variables are missing
+// for the first few frames.
+func (e *Engine) startSystemInfo() {
+	e.stopSystemInfo()
+
+	info := e.systemInfo
+	if info == nil {
+		sysfs := NewSysfsInfo(e.config.SystemInfo)
+		// The helper programs only run for configs that show their values.
+		used := referencedVariables(e.config)
+		if !used["volume"] {
+			sysfs.VolumeCommand = nil
+		}
+		if !used["wifissid"] {
+			sysfs.SSIDCommand = nil
+		}
+		info = sysfs
+	}
+	interval := e.config.SystemInfo.interval()
+	stop := make(chan struct{})
+	e.systemStop = stop
+	go func() {
+		var tick <-chan time.Time
+		if interval > 0 {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			values := info.Read()
+			e.post(func() {
+				if e.systemStop == stop { // Not stopped meanwhile
+					e.setSystemValues(values)
+				}
+			})
+			if tick == nil {
+				return
+			}
+			select {
+			case <-stop:
+				return
+			case <-tick:
+			}
+		}
+	}()
+}
+
+// referencedVariables returns the lower-cased names of the variables config
+// refers to anywhere: in text, trigger parameters, conditions and commands.
+// Only the first part of a path counts, so $user.name is user.
+func referencedVariables(config *Config) map[string]bool {
+	used := make(map[string]bool)
+	data, err := json.Marshal(config)
+	if err != nil {
+		return used
+	}
+	for _, m := range variablePattern.FindAllStringSubmatch(string(data), -1) {
+		name, _, _ := strings.Cut(parseVariableRef(m).Name, ".")
+		used[strings.ToLower(name)] = true
+	}
+	return used
+}
+
+func (e *Engine) stopSystemInfo() {
+	if e.systemStop != nil {
+		close(e.systemStop)
+		e.systemStop = nil
+	}
+}
+
+func (e *Engine) setSystemValues(values map[string]interface{}) {
+	e.config.Variables.System = values
+	e.Invalidate()
+}
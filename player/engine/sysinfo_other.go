@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package engine
+
+// freeSpace is not implemented on this platform; $storageFree stays
+// missing.
+func freeSpace(path string) (uint64, bool) {
+	return 0, false
+}
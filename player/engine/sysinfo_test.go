@@ -0,0 +1,153 @@
+package engine
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// The trees under testdata/sysfs stand in for / on a few kinds of device.
+
+func TestSysfsBattery(t *testing.T) {
+	tests := []struct {
+		root     string
+		capacity int
+		charging bool
+		ok       bool
+	}{
+		{"testdata/sysfs/handheld", 57, false, true},
+		{"testdata/sysfs/charging", 80, true, true}, // BAT0 has no readable capacity
+		{"testdata/sysfs/desktop", 0, false, false},
+		{"testdata/sysfs/missing", 0, false, false},
+	}
+	for _, tt := range tests {
+		s := &SysfsInfo{Root: tt.root}
+		capacity, charging, ok := s.battery()
+		if capacity != tt.capacity || charging != tt.charging || ok != tt.ok {
+			t.Errorf("%s: battery() = %d, %v, %v, want %d, %v, %v",
+				tt.root, capacity, charging, ok, tt.capacity, tt.charging, tt.ok)
+		}
+	}
+}
+
+func TestSysfsBrightness(t *testing.T) {
+	tests := []struct {
+		root       string
+		brightness int
+		ok         bool
+	}{
+		{"testdata/sysfs/handheld", 50, true},
+		{"testdata/sysfs/charging", 78, true}, // acpi_video0 has a zero max_brightness
+		{"testdata/sysfs/desktop", 0, false},
+	}
+	for _, tt := range tests {
+		s := &SysfsInfo{Root: tt.root}
+		if brightness, ok := s.brightness(); brightness != tt.brightness || ok != tt.ok {
+			t.Errorf("%s: brightness() = %d, %v, want %d, %v", tt.root, brightness, ok, tt.brightness, tt.ok)
+		}
+	}
+}
+
+func TestSysfsIP(t *testing.T) {
+	addr := func(cidr string) net.Addr {
+		ip, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n.IP = ip
+		return n
+	}
+	tests := []struct {
+		name  string
+		root  string
+		addrs map[string][]net.Addr
+		want  string
+	}{
+		{
+			name: "skips interfaces that are down",
+			root: "testdata/sysfs/handheld",
+			addrs: map[string][]net.Addr{
+				"eth0":  {addr("172.16.0.5/16")},
+				"wlan0": {addr("fe80::2/64"), addr("192.168.1.23/24")},
+			},
+			want: "192.168.1.23",
+		},
+		{
+			name: "skips IPv6 and unreadable interfaces",
+			root: "testdata/sysfs/charging",
+			addrs: map[string][]net.Addr{
+				"eth0": {addr("fe80::1/64")},
+			},
+			want: "",
+		},
+		{
+			name: "skips loopback",
+			root: "testdata/sysfs/desktop",
+			addrs: map[string][]net.Addr{
+				"lo": {addr("127.0.0.1/8")},
+			},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		s := &SysfsInfo{Root: tt.root, InterfaceAddrs: func(name string) ([]net.Addr, error) {
+			if list, ok := tt.addrs[name]; ok {
+				return list, nil
+			}
+			return nil, errors.New("no such interface")
+		}}
+		if got := s.ip(); got != tt.want {
+			t.Errorf("%s: ip() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSysfsRead(t *testing.T) {
+	s := &SysfsInfo{
+		Root:           "testdata/sysfs/handheld",
+		TimeFormat:     "3:04 PM",
+		InterfaceAddrs: func(string) ([]net.Addr, error) { return nil, nil },
+		Now:            func() time.Time { return time.Date(2024, 5, 17, 14, 30, 0, 0, time.UTC) },
+	}
+	values := s.Read()
+	want := map[string]interface{}{
+		"time":       "2:30 PM",
+		"date":       "2024-05-17",
+		"battery":    57,
+		"charging":   false,
+		"brightness": 50,
+		"hostname":   "trimui",
+		"ip":         "",
+	}
+	for name, value := range want {
+		if values[name] != value {
+			t.Errorf("%s = %#v, want %#v", name, values[name], value)
+		}
+	}
+	for _, name := range []string{"volume", "wifiSsid"} {
+		if _, ok := values[name]; ok {
+			t.Errorf("%s is set without a command to read it", name)
+		}
+	}
+}
+
+func TestReferencedVariables(t *testing.T) {
+	config := &Config{
+		Variables: Variables{Custom: map[string]interface{}{"greeting": "Hi $user.name"}},
+		Scenes: []SceneConfig{{Name: "Home", Elements: []Element{
+			{Type: "label", Text: "Vol ${volume:%d|?}"},
+			{Type: "button", VisibleIf: "$Charging && $battery < 20"},
+			{Type: "dynamiclist", Command: "ls $romDir"},
+		}}},
+	}
+	used := referencedVariables(config)
+	for _, name := range []string{"user", "volume", "charging", "battery", "romdir"} {
+		if !used[name] {
+			t.Errorf("%s is not found", name)
+		}
+	}
+	if used["wifissid"] {
+		t.Error("wifissid is found but not used")
+	}
+}
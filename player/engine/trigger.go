@@ -0,0 +1,233 @@
+package engine
+
+import (
+	"context"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// changeScene moves to the next or previous scene, skipping modal scenes.
+// It does nothing while a modal scene is open.
+func (e *Engine) changeScene(direction int) {
+	if e.Scene().Modal {
+		return
+	}
+	index := e.currentSceneIndex
+	for range e.config.Scenes {
+		index += direction
+		if index < 0 {
+			index = len(e.config.Scenes) - 1
+		} else if index >= len(e.config.Scenes) {
+			index = 0
+		}
+		if !e.config.Scenes[index].Modal {
+			break
+		}
+	}
+	e.enterScene(index)
+}
+
+// clearSceneState drops what was cached for the scene being left.
+func (e *Engine) clearSceneState() {
+	if e.resources != nil {
+		e.resources.Clear()
+	}
+	e.dynamicLists = make(map[string]*dynamicList)
+	e.listLoads = make(map[string]bool)
+	e.stopConsoles()
+}
+
+func (e *Engine) notifySceneChange(from int) {
+	if from == e.currentSceneIndex {
+		return
+	}
+	for _, fn := range e.sceneChangeHooks {
+		fn(e, from, e.currentSceneIndex)
+	}
+}
+
+func (e *Engine) moveSelection(direction int) {
+	currentScene := e.config.Scenes[e.currentSceneIndex]
+	elements := currentScene.Elements
+
+	// Create a list of navigable element indices (buttons, inputs and dynamic lists, skip menus
+	// and anything hidden or disabled)
+	var interactive []int
+	for i, el := range elements {
+		if e.focusable(el) {
+			interactive = append(interactive, i)
+		}
+	}
+
+	// No interactive elements available
+	if len(interactive) == 0 {
+		e.selectedButtonIndex = -1
+		return
+	}
+
+	// Find current position in interactive list
+	currentIdx := -1
+	for idx, val := range interactive {
+		if val == e.selectedButtonIndex {
+			currentIdx = idx
+			break
+		}
+	}
+
+	// Handle new selections or wrap around
+	if currentIdx == -1 {
+		e.selectedButtonIndex = interactive[0]
+		return
+	}
+
+	// Calculate new index with wrap-around
+	newIdx := currentIdx + direction
+	if newIdx >= len(interactive) {
+		newIdx = 0
+	} else if newIdx < 0 {
+		newIdx = len(interactive) - 1
+	}
+
+	// Update selection
+	e.selectedButtonIndex = interactive[newIdx]
+}
+
+func (e *Engine) triggerSelectedElement() {
+	selectedElement := e.config.Scenes[e.currentSceneIndex].Elements[e.selectedButtonIndex]
+	switch selectedElement.Type {
+	case "button":
+		e.handleTrigger(selectedElement)
+	case "dynamiclist":
+		e.cycleDynamicList(e.selectedButtonIndex, selectedElement)
+	}
+}
+
+// handleTrigger runs the element's actions, or its single trigger.
+func (e *Engine) handleTrigger(element Element) {
+	e.runActions(element, element.actionList())
+}
+
+// runTrigger performs element's trigger. Triggers that finish later, like
+// a program or a video, return true and call resume on the main loop once
+// they are done.
+func (e *Engine) runTrigger(element Element, resume func()) bool {
+	renderer, config := e.renderer, e.config
+
+	switch element.Trigger {
+	case "set_variable":
+		if element.TriggerTarget != "" {
+			// The old value may be an image path; don't keep its texture around.
+			if old, ok := config.Variables.Custom[element.TriggerTarget].(string); ok {
+				e.resources.Invalidate(old)
+			}
+			e.setVariable(element.TriggerTarget, element.TriggerValue)
+		}
+
+	case "increment", "decrement":
+		if element.TriggerTarget != "" {
+			sign := 1.0
+			if element.Trigger == "decrement" {
+				sign = -1
+			}
+			e.stepVariable(element, sign)
+		}
+	case "toggle":
+		if element.TriggerTarget != "" {
+			e.toggleVariable(element.TriggerTarget)
+		}
+
+	case "external_app":
+		if element.Background || element.Job != "" {
+			return e.startApp(element, resume)
+		}
+		cmd, err := e.appCommand(context.Background(), element)
+		if err != nil {
+			processLog.Error("cannot start external app", "target", element.TriggerTarget, "err", err)
+			break
+		}
+		return e.runForeground(cmd, element, resume)
+	case "cancel_job":
+		if element.TriggerTarget != "" {
+			e.cancelJob(substituteVariables(element.TriggerTarget, config))
+		}
+
+	case "play_video":
+		video := substituteVariables(element.TriggerTarget, config)
+		if err := e.pathAllowed(video); err != nil {
+			processLog.Error("cannot play video", "video", video, "err", err)
+			break
+		}
+		e.startProcess(exec.Command("ffplay", video, "-fs", "-autoexit"), func(result processResult) {
+			if result.err != nil {
+				processLog.Error("video playback failed", "video", video, "err", result.err)
+			}
+			resume()
+		})
+		return true
+
+	case "wait":
+		ms, err := strconv.Atoi(substituteVariables(element.TriggerValue, config))
+		if err != nil || ms <= 0 {
+			triggerLog.Warn("wait needs a positive triggerValue in milliseconds", "value", element.TriggerValue)
+			break
+		}
+		time.AfterFunc(time.Duration(ms)*time.Millisecond, func() { e.post(resume) })
+		return true
+
+	case "play_image":
+		texture := e.loadTexture(substituteVariables(element.TriggerTarget, config))
+		if texture != nil {
+			renderer.Copy(texture, nil, nil)
+			renderer.Present()
+			sdl.Delay(3000)
+		}
+	case "reset_variables":
+		e.resetVariables(element.TriggerTarget)
+	case "exit":
+		e.Stop()
+	case "change_scene":
+		if element.TriggerTarget == "" {
+			break
+		}
+		if name := substituteVariables(element.TriggerTarget, config); !e.ChangeScene(name) {
+			triggerLog.Warn("no scene to change to", "scene", name)
+		}
+	case "push_scene":
+		if name := substituteVariables(element.TriggerTarget, config); !e.PushScene(name) {
+			triggerLog.Warn("no scene to push", "scene", name)
+		}
+	case "pop_scene", "back":
+		e.popScene()
+	default:
+		if fn, ok := e.triggers[element.Trigger]; ok {
+			fn(e, element)
+		}
+	}
+	return false
+}
+
+func (e *Engine) findFirstSelectableElement(scene SceneConfig) int {
+	for i, element := range scene.Elements {
+		if e.focusable(element) {
+			return i
+		}
+	}
+	return -1
+}
+
+// focusable reports whether element can take the focus right now: it has
+// to be a selectable type, visible and enabled.
+func (e *Engine) focusable(element Element) bool {
+	return isSelectable(element) && e.enabled(element)
+}
+
+func isSelectable(element Element) bool {
+	switch element.Type {
+	case "button", "input", "dynamiclist", "console":
+		return true
+	}
+	return false
+}
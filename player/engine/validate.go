@@ -0,0 +1,625 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Severity tells whether a Problem stops the player from starting.
+type Severity int
+
+const (
+	SeverityWarning Severity = iota
+	SeverityError
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// Problem is a single issue found by Validate. Element is -1 for problems
+// that belong to the scene or the config as a whole; Scene is empty for the
+// latter.
+type Problem struct {
+	Severity Severity
+	Scene    string
+	Element  int
+	Type     string
+	Field    string
+	Message  string
+}
+
+func (p Problem) String() string {
+	var where []string
+	if p.Scene != "" {
+		where = append(where, fmt.Sprintf("scene %q", p.Scene))
+	}
+	if p.Element >= 0 {
+		where = append(where, fmt.Sprintf("element %d (%s)", p.Element, p.Type))
+	}
+	if p.Field != "" {
+		where = append(where, p.Field)
+	}
+	return fmt.Sprintf("%s: %s: %s", p.Severity, strings.Join(where, ", "), p.Message)
+}
+
+// HasErrors reports whether any of problems is an error rather than a
+// warning.
+func HasErrors(problems []Problem) bool {
+	for _, p := range problems {
+		if p.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// knownElementTypes are the element types renderScene can draw.
+var knownElementTypes = map[string]bool{
+	"label":         true,
+	"button":        true,
+	"image":         true,
+	"input":         true,
+	"video":         true,
+	"menu":          true,
+	"collapsedlist": true,
+	"dynamiclist":   true,
+	"console":       true,
+}
+
+// knownTriggers are the triggers handleTrigger understands.
+var knownTriggers = map[string]bool{
+	"set_variable":    true,
+	"external_app":    true,
+	"play_video":      true,
+	"play_image":      true,
+	"exit":            true,
+	"change_scene":    true,
+	"wait":            true,
+	"reset_variables": true,
+	"increment":       true,
+	"decrement":       true,
+	"toggle":          true,
+	"push_scene":      true,
+	"pop_scene":       true,
+	"back":            true,
+	"cancel_job":      true,
+}
+
+// Validate checks config for problems the player would otherwise only hit
+// at runtime: unknown element types and triggers, missing scenes and
+// variables, malformed colors, unknown fonts and missing files. Relative
+// file paths are resolved against dir.
+func Validate(config *Config, dir string) []Problem {
+	v := validator{config: config, dir: dir}
+	return v.run()
+}
+
+// Validate is like the package-level Validate but also accepts triggers
+// registered with RegisterTrigger.
+func (e *Engine) Validate(dir string) []Problem {
+	v := validator{config: e.config, dir: dir, triggers: e.triggers}
+	return v.run()
+}
+
+type validator struct {
+	config   *Config
+	dir      string
+	triggers map[string]TriggerFunc
+	problems []Problem
+
+	// Variables that only come into existence at runtime.
+	runtimeVariables map[string]bool
+	jobs             map[string]bool // Lower-cased job names external_app actions start
+	security         *Policy         // The config's own policy, relative to dir
+
+	scene   string
+	element int
+	typ     string
+}
+
+func (v *validator) report(severity Severity, field, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{
+		Severity: severity,
+		Scene:    v.scene,
+		Element:  v.element,
+		Type:     v.typ,
+		Field:    field,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) run() []Problem {
+	config := v.config
+	v.element = -1
+
+	if len(config.Scenes) == 0 {
+		v.report(SeverityError, "scenes", "config has no scenes")
+	}
+	if config.Resolution.Width < 0 || config.Resolution.Height < 0 {
+		v.report(SeverityError, "resolution", "width and height must not be negative")
+	}
+	if _, problems := newBindings(config.Input); problems != nil {
+		for _, p := range problems {
+			v.report(SeverityError, p.field, "%s", p.message)
+		}
+	}
+	switch config.Resolution.Scale {
+	case "", ScaleLetterbox, ScaleStretch, ScaleInteger:
+	default:
+		v.report(SeverityError, "resolution.scale", "unknown scale mode %q, expected letterbox, stretch or integer", config.Resolution.Scale)
+	}
+
+	// Inputs, set_variable triggers and list commands all create variables
+	// while the player runs, so references to them are fine.
+	v.runtimeVariables = persistentVariables(config)
+	v.jobs = make(map[string]bool)
+	for _, name := range systemVariables {
+		v.runtimeVariables[strings.ToLower(name)] = true
+	}
+	for _, scene := range config.Scenes {
+		for _, element := range scene.Elements {
+			if element.Type == "input" && element.Variable != "" {
+				v.runtimeVariables[strings.ToLower(element.Variable)] = true
+			}
+			if element.ListVariable != "" {
+				v.runtimeVariables[strings.ToLower(element.ListVariable)] = true
+			}
+			if element.Type == "dynamiclist" && element.Variable != "" {
+				v.runtimeVariables[strings.ToLower(element.Variable)] = true
+			}
+			if element.Type == "console" && element.Job != "" {
+				v.runtimeVariables[strings.ToLower(element.Job)] = true
+				v.jobs[strings.ToLower(element.Job)] = true
+			}
+		}
+		lists := sceneActions(scene)
+		for _, element := range scene.Elements {
+			lists = append(lists, namedActions{actions: element.actionList()})
+		}
+		for _, actions := range lists {
+			for _, action := range flattenActions(actions.actions) {
+				if name := writtenVariable(action); name != "" {
+					v.runtimeVariables[strings.ToLower(name)] = true
+				}
+				if action.ExternalAppReturn != "" {
+					v.runtimeVariables[strings.ToLower(action.ExternalAppReturn)] = true
+				}
+				if action.Trigger == "external_app" && action.Job != "" {
+					v.runtimeVariables[strings.ToLower(action.Job)] = true
+					v.jobs[strings.ToLower(action.Job)] = true
+				}
+			}
+		}
+	}
+
+	v.checkFile("variables.backgroundImage", config.Variables.BackgroundImage)
+	for key, path := range config.Variables.Fonts {
+		v.checkFile("variables.fonts."+key, path)
+	}
+
+	if config.Security != nil {
+		policy := *config.Security
+		policy.dir = v.dir
+		v.security = &policy
+		for i, pattern := range policy.Executables {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				v.report(SeverityError, fmt.Sprintf("security.executables[%d]", i), "malformed pattern %q", pattern)
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, scene := range config.Scenes {
+		v.scene, v.element, v.typ = scene.Name, -1, ""
+		if scene.Name == "" {
+			v.report(SeverityWarning, "name", "scene has no name")
+		} else if seen[scene.Name] {
+			v.report(SeverityWarning, "name", "duplicate scene name; change_scene will always pick the first one")
+		}
+		seen[scene.Name] = true
+
+		for i, timer := range scene.OnTimer {
+			if timer.Interval <= 0 {
+				v.report(SeverityError, fmt.Sprintf("onTimer[%d].interval", i), "interval must be a positive number of milliseconds")
+			}
+		}
+		for _, actions := range sceneActions(scene) {
+			for i, action := range actions.actions {
+				v.checkAction(fmt.Sprintf("%s[%d].", actions.field, i), action)
+			}
+		}
+
+		for i, element := range scene.Elements {
+			v.element, v.typ = i, element.Type
+			v.checkElement(element)
+		}
+	}
+	return v.problems
+}
+
+func (v *validator) checkElement(element Element) {
+	if !knownElementTypes[element.Type] {
+		v.report(SeverityError, "type", "unknown element type %q", element.Type)
+	}
+
+	if len(element.Actions) > 0 {
+		if element.Trigger != "" {
+			v.report(SeverityWarning, "trigger", "ignored because the element has actions")
+		}
+		for i, action := range element.Actions {
+			v.checkAction(fmt.Sprintf("actions[%d].", i), action)
+		}
+	} else {
+		v.checkAction("", Action{
+			Trigger:       element.Trigger,
+			TriggerTarget: element.TriggerTarget,
+			TriggerValue:  element.TriggerValue,
+			Background:    element.Background,
+			OnReturn:      element.OnReturn,
+			Args:          element.Args,
+			Cwd:           element.Cwd,
+			Env:           element.Env,
+			Shell:         element.Shell,
+			Job:           element.Job,
+			Timeout:       element.Timeout,
+		})
+	}
+
+	v.checkColor("color", element.Color)
+	v.checkColor("bgColor", element.BgColor)
+	v.checkFont(element.Font)
+
+	v.checkCondition("visibleIf", element.VisibleIf)
+	v.checkCondition("enabledIf", element.EnabledIf)
+	v.checkVariables("text", element.Text)
+	v.checkSize("width", string(element.Width))
+	v.checkSize("height", string(element.Height))
+
+	if element.Type == "image" {
+		v.checkFile("image", element.Image)
+	}
+	if element.Type == "video" {
+		v.checkFile("video", element.Video)
+	}
+	if element.Type == "collapsedlist" && element.Command != "" && element.ListVariable == "" {
+		v.report(SeverityWarning, "listVariable", "command output is discarded without a listVariable")
+	}
+	if element.Type == "input" && element.Variable == "" {
+		v.report(SeverityWarning, "variable", "input is not bound to a variable")
+	}
+	if element.Type == "dynamiclist" && element.Command == "" {
+		v.report(SeverityWarning, "command", "dynamic list has no command and will stay empty")
+	}
+	switch element.Type {
+	case "collapsedlist", "dynamiclist", "console":
+		if element.Command != "" {
+			v.checkShell("command")
+		}
+	}
+	if element.Type == "console" {
+		if element.Command == "" {
+			v.report(SeverityWarning, "command", "console has no command and will stay empty")
+		}
+		if element.Timeout < 0 {
+			v.report(SeverityError, "timeout", "timeout must be a positive number of milliseconds")
+		}
+		if element.Font == "" && !v.hasFont("console") {
+			v.report(SeverityWarning, "font", "no \"console\" font in variables.fonts; the default font is not monospaced, so columns won't line up")
+		}
+	}
+	if element.Opacity != nil && (*element.Opacity < 0 || *element.Opacity > 1) {
+		v.report(SeverityError, "opacity", "%v is outside 0 to 1", *element.Opacity)
+	}
+}
+
+// namedActions is one of a scene's action lists with the field it came from.
+type namedActions struct {
+	field   string
+	actions []Action
+}
+
+func sceneActions(scene SceneConfig) []namedActions {
+	lists := []namedActions{
+		{"onEnter", scene.OnEnter},
+		{"onExit", scene.OnExit},
+	}
+	for i, timer := range scene.OnTimer {
+		lists = append(lists, namedActions{fmt.Sprintf("onTimer[%d].actions", i), timer.Actions})
+	}
+	return lists
+}
+
+// flattenActions returns actions together with the steps of every branch
+// inside them.
+func flattenActions(actions []Action) []Action {
+	var all []Action
+	for _, action := range actions {
+		all = append(all, action)
+		all = append(all, flattenActions(action.Then)...)
+		all = append(all, flattenActions(action.Else)...)
+		all = append(all, flattenActions(action.OnReturn)...)
+	}
+	return all
+}
+
+// checkAction checks a trigger and its parameters. prefix is prepended to
+// the field names in reports.
+func (v *validator) checkAction(prefix string, action Action) {
+	if action.If != "" {
+		v.checkCondition(prefix+"if", action.If)
+		for i, step := range action.Then {
+			v.checkAction(fmt.Sprintf("%sthen[%d].", prefix, i), step)
+		}
+		for i, step := range action.Else {
+			v.checkAction(fmt.Sprintf("%selse[%d].", prefix, i), step)
+		}
+		if action.Trigger == "" {
+			return
+		}
+	} else if len(action.Then) > 0 || len(action.Else) > 0 {
+		v.report(SeverityError, prefix+"if", "then and else need an if condition")
+		return
+	}
+	if action.Trigger == "" {
+		if prefix != "" {
+			v.report(SeverityError, prefix+"trigger", "action has no trigger")
+		}
+		return
+	}
+	if !knownTriggers[action.Trigger] {
+		if _, ok := v.triggers[action.Trigger]; !ok {
+			v.report(SeverityError, prefix+"trigger", "unknown trigger %q", action.Trigger)
+		}
+	}
+	if action.Trigger != "external_app" {
+		for _, option := range appOptions(action) {
+			v.report(SeverityWarning, prefix+option, "only applies to external_app")
+		}
+	}
+	for i, step := range action.OnReturn {
+		v.checkAction(fmt.Sprintf("%sonReturn[%d].", prefix, i), step)
+	}
+	switch action.Trigger {
+	case "change_scene", "push_scene":
+		v.checkSceneRef(prefix+"triggerTarget", action.Trigger, action.TriggerTarget)
+	case "set_variable", "external_app", "toggle":
+		if action.TriggerTarget == "" {
+			v.report(SeverityError, prefix+"triggerTarget", "%s needs a triggerTarget", action.Trigger)
+		}
+		if action.Trigger == "external_app" {
+			v.checkAppCommand(prefix, action)
+		}
+	case "increment", "decrement":
+		if action.TriggerTarget == "" {
+			v.report(SeverityError, prefix+"triggerTarget", "%s needs a triggerTarget", action.Trigger)
+		}
+		if action.TriggerValue != "" && !strings.Contains(action.TriggerValue, "$") {
+			if _, err := strconv.ParseFloat(action.TriggerValue, 64); err != nil {
+				v.report(SeverityError, prefix+"triggerValue", "%s needs a number to step by", action.Trigger)
+			}
+		}
+	case "play_image", "play_video":
+		v.checkFile(prefix+"triggerTarget", action.TriggerTarget)
+	case "cancel_job":
+		switch {
+		case action.TriggerTarget == "":
+			v.report(SeverityError, prefix+"triggerTarget", "cancel_job needs the job's name")
+		case !strings.Contains(action.TriggerTarget, "$") && !v.jobs[strings.ToLower(action.TriggerTarget)]:
+			v.report(SeverityWarning, prefix+"triggerTarget", "no external_app starts a job named %q", action.TriggerTarget)
+		}
+	case "wait":
+		if !strings.Contains(action.TriggerValue, "$") {
+			if ms, err := strconv.Atoi(action.TriggerValue); err != nil || ms <= 0 {
+				v.report(SeverityError, prefix+"triggerValue", "wait needs a positive number of milliseconds")
+			}
+		}
+	}
+}
+
+// checkAppCommand checks external_app's command line.
+func (v *validator) checkAppCommand(prefix string, action Action) {
+	if action.Shell {
+		v.checkShell(prefix + "shell")
+	} else if v.security != nil && !strings.Contains(action.TriggerTarget, "$") {
+		dir := v.dir
+		if action.Cwd != "" && !strings.Contains(action.Cwd, "$") {
+			dir = abs(v.dir, action.Cwd)
+		}
+		if err := v.security.allowsProgram(action.TriggerTarget, dir); err != nil {
+			v.report(SeverityError, prefix+"triggerTarget", "the config's security settings refuse this: %v", err)
+		}
+	}
+	if v.security != nil && v.security.Confirm && programFromVariables(action.Shell, action.TriggerTarget, action.Cwd, action.Env) {
+		v.report(SeverityError, prefix+"triggerTarget", "with security.confirm on, the program can't depend on variables: the user couldn't see what runs")
+	}
+	if action.Timeout < 0 {
+		v.report(SeverityError, prefix+"timeout", "timeout must be a positive number of milliseconds")
+	} else if action.Timeout > 0 && !action.Background && action.Job == "" {
+		v.report(SeverityWarning, prefix+"timeout", "only applies to background apps and jobs")
+	}
+	if action.Job != "" && strings.Contains(action.Job, "$") {
+		v.report(SeverityWarning, prefix+"job", "job names are used as written; variables in them are not substituted")
+	}
+	if !action.Shell && len(action.Args) == 0 && strings.ContainsAny(strings.TrimSpace(action.TriggerTarget), " \t") {
+		v.report(SeverityWarning, prefix+"triggerTarget", "the whole text is the program name; put arguments in args or set shell")
+	}
+	for i, arg := range action.Args {
+		v.checkVariables(fmt.Sprintf("%sargs[%d]", prefix, i), arg)
+	}
+	for name, value := range action.Env {
+		if name == "" || strings.ContainsAny(name, "=\x00") {
+			v.report(SeverityError, prefix+"env", "invalid environment variable name %q", name)
+		}
+		v.checkVariables(prefix+"env."+name, value)
+	}
+	if action.Cwd == "" {
+		return
+	}
+	if strings.Contains(action.Cwd, "$") {
+		v.checkVariables(prefix+"cwd", action.Cwd)
+		return
+	}
+	dir := action.Cwd
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(v.dir, dir)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		v.report(SeverityError, prefix+"cwd", "directory %q not found", dir)
+	}
+}
+
+// checkShell reports a shell command the config's own policy forbids.
+func (v *validator) checkShell(field string) {
+	if v.security == nil {
+		return
+	}
+	if err := v.security.allowsShell(); err != nil {
+		v.report(SeverityError, field, "the config's security settings refuse this: %v", err)
+	}
+}
+
+func (v *validator) checkSceneRef(field, trigger, name string) {
+	if name == "" {
+		v.report(SeverityError, field, "%s needs a target scene", trigger)
+		return
+	}
+	if strings.Contains(name, "$") {
+		return
+	}
+	for _, scene := range v.config.Scenes {
+		if scene.Name == name {
+			return
+		}
+	}
+	v.report(SeverityError, field, "no scene named %q", name)
+}
+
+// checkVariables reports $name references that cannot be resolved. Only
+// the first part of a dotted path is checked, since lists and objects are
+// often filled in at runtime.
+func (v *validator) checkVariables(field, text string) bool {
+	ok := true
+	for _, m := range variablePattern.FindAllStringSubmatch(text, -1) {
+		ref := parseVariableRef(m)
+		if ref.HasDefault {
+			continue
+		}
+		if _, found := v.config.Variables.Value(ref.Name); found {
+			continue
+		}
+		root, _, _ := strings.Cut(ref.Name, ".")
+		if _, found := v.config.Variables.Value(root); found || v.runtimeVariables[strings.ToLower(root)] {
+			continue
+		}
+		v.report(SeverityWarning, field, "undefined variable $%s", ref.Name)
+		ok = false
+	}
+	return ok
+}
+
+// checkCondition reports conditions that don't parse. Undefined variables
+// are fine here: testing whether one is set is what conditions are for.
+func (v *validator) checkCondition(field, condition string) {
+	if strings.TrimSpace(condition) == "" {
+		return
+	}
+	if _, err := parseExpr(condition); err != nil {
+		v.report(SeverityError, field, "%v", err)
+	}
+}
+
+func (v *validator) checkColor(field, color string) {
+	if color == "" {
+		return
+	}
+	if strings.HasPrefix(color, "$") {
+		if !v.checkVariables(field, color) {
+			return
+		}
+		value, found := v.config.Variables.Lookup(color[1:])
+		if !found {
+			return
+		}
+		parts := strings.Split(value, ",")
+		if len(parts) != 3 {
+			v.report(SeverityWarning, field, "variable %s is %q, not an r,g,b color", color, value)
+			return
+		}
+		for _, part := range parts {
+			if n, err := strconv.Atoi(strings.TrimSpace(part)); err != nil || n < 0 || n > 255 {
+				v.report(SeverityWarning, field, "variable %s is %q, not an r,g,b color", color, value)
+				return
+			}
+		}
+		return
+	}
+	if _, _, _, ok := parseHexColor(color); !ok {
+		v.report(SeverityError, field, "invalid color %q, expected #rrggbb", color)
+	}
+}
+
+// hasFont reports whether variables.fonts has a file for the font key.
+func (v *validator) hasFont(font string) bool {
+	for key := range v.config.Variables.Fonts {
+		if strings.EqualFold(key, font) {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *validator) checkFont(font string) {
+	if font == "" || v.hasFont(font) {
+		return
+	}
+	for key := range v.config.Variables.FontSizes {
+		if strings.EqualFold(key, font) {
+			return
+		}
+	}
+	v.report(SeverityWarning, "font", "unknown font %q, the default font will be used", font)
+}
+
+func (v *validator) checkSize(field, size string) {
+	if size == "" {
+		return
+	}
+	if !v.checkVariables(field, size) {
+		return
+	}
+	if strings.Contains(size, "$") {
+		return
+	}
+	if _, err := strconv.Atoi(size); err != nil {
+		v.report(SeverityError, field, "%q is not a number", size)
+	}
+}
+
+func (v *validator) checkFile(field, path string) {
+	if path == "" {
+		return
+	}
+	if _, inline, err := decodeInlineImage(path); inline {
+		if err != nil {
+			v.report(SeverityError, field, "invalid inline image: %v", err)
+		}
+		return
+	}
+	if strings.Contains(path, "$") {
+		v.checkVariables(field, path)
+		return
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(v.dir, path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		v.report(SeverityError, field, "file %q not found", path)
+	}
+}
@@ -0,0 +1,229 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// variablePattern matches variable references in element text and fields:
+//
+//	$name            a variable
+//	$user.name       a field of an object variable
+//	$items.0.title   a field of the first entry of a list variable
+//	${price:%.2f}    a variable formatted with a fmt verb
+//	${name|Guest}    a variable with a default for when it's missing or empty
+//
+// The braced form takes the same paths as the plain one and may combine a
+// format with a default, as in ${score:%05d|0}.
+var variablePattern = regexp.MustCompile(`\$\{([^}]*)\}|\$(\w+(?:\.\w+)*)`)
+
+// variableRef is one parsed variablePattern match.
+type variableRef struct {
+	Name       string
+	Format     string
+	Default    string
+	HasDefault bool
+}
+
+// parseVariableRef splits a variablePattern submatch into its parts.
+func parseVariableRef(m []string) variableRef {
+	if m[1] == "" && m[2] != "" {
+		return variableRef{Name: m[2]}
+	}
+	var ref variableRef
+	body := m[1]
+	body, ref.Default, ref.HasDefault = strings.Cut(body, "|")
+	ref.Name, ref.Format, _ = strings.Cut(body, ":")
+	ref.Name = strings.TrimSpace(ref.Name)
+	return ref
+}
+
+// resolve returns the text ref stands for. Missing and empty variables
+// fall back to the default; the second result is false only when there is
+// no value and no default.
+func (v *Variables) resolve(ref variableRef) (string, bool) {
+	value, ok := v.Value(ref.Name)
+	if !ok || value == nil || value == "" {
+		if ref.HasDefault {
+			return ref.Default, true
+		}
+		return "", ok
+	}
+	if ref.Format != "" {
+		return formatWith(ref.Format, value), true
+	}
+	return formatValue(value), true
+}
+
+// expand returns the text a variablePattern submatch stands for. A plain
+// reference whose path doesn't resolve falls back to its longest prefix
+// naming a plain value, so "$theme.png" is still the theme variable followed
+// by ".png".
+func (v *Variables) expand(m []string) (string, bool) {
+	ref := parseVariableRef(m)
+	text, ok := v.resolve(ref)
+	if ok || m[2] == "" {
+		return text, ok
+	}
+	name := ref.Name
+	for i := strings.LastIndexByte(name, '.'); i > 0; i = strings.LastIndexByte(name, '.') {
+		name = name[:i]
+		value, ok := v.Value(name)
+		if !ok {
+			continue
+		}
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			return "", false // A path into it that doesn't exist
+		}
+		return formatValue(value) + ref.Name[len(name):], true
+	}
+	return "", false
+}
+
+// formatValue turns a variable's value into the text shown for it. Lists
+// and objects are written as JSON.
+func formatValue(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(value)
+	case bool:
+		return strconv.FormatBool(value)
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprintf("%v", value)
+		}
+		return string(data)
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
+
+// formatWith formats value with a fmt verb such as "%.2f" or "05d"; the
+// leading % is optional. Numeric verbs convert text holding a number, so
+// they work on values read from inputs and programs as well.
+func formatWith(format string, value interface{}) string {
+	if !strings.HasPrefix(format, "%") {
+		format = "%" + format
+	}
+	switch format[len(format)-1] {
+	case 'd', 'x', 'X', 'o', 'b', 'c':
+		if n, ok := toNumber(value); ok {
+			return fmt.Sprintf(format, int64(n))
+		}
+	case 'f', 'F', 'e', 'E', 'g', 'G':
+		if n, ok := toNumber(value); ok {
+			return fmt.Sprintf(format, n)
+		}
+	case 't':
+		return fmt.Sprintf(format, truthy(formatValue(value)))
+	}
+	return fmt.Sprintf(format, formatValue(value))
+}
+
+// toNumber converts a number, or text holding one, to a float64.
+func toNumber(value interface{}) (float64, bool) {
+	switch value := value.(type) {
+	case float64:
+		return value, true
+	case int:
+		return float64(value), true
+	case bool:
+		if value {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// lookupPath walks path through nested objects and lists. Object keys
+// ignore case, like variable names; list entries are numbered from 0.
+func lookupPath(value interface{}, path []string) (interface{}, bool) {
+	for _, part := range path {
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+		default:
+			// Typed values, like the collapsed list items, are walked in
+			// their JSON form.
+			data, err := json.Marshal(value)
+			if err != nil {
+				return nil, false
+			}
+			if err := json.Unmarshal(data, &value); err != nil {
+				return nil, false
+			}
+		}
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			found := false
+			for key, field := range v {
+				if strings.EqualFold(key, part) {
+					value, found = field, true
+					break
+				}
+			}
+			if !found {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			value = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// writtenVariable returns the variable action stores a value in, or "".
+// external_app's return variable is not included.
+func writtenVariable(action Action) string {
+	switch action.Trigger {
+	case "set_variable", "increment", "decrement", "toggle":
+		return action.TriggerTarget
+	}
+	return ""
+}
+
+// stepVariable adds the action's triggerValue, 1 if empty, to a number
+// variable; decrement subtracts it. A missing or non-numeric variable
+// starts from 0.
+func (e *Engine) stepVariable(element Element, sign float64) {
+	step := 1.0
+	if text := substituteVariables(element.TriggerValue, e.config); text != "" {
+		n, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			triggerLog.Warn("step must be a number", "trigger", element.Trigger, "value", text)
+			return
+		}
+		step = n
+	}
+	current, _ := e.config.Variables.Value(element.TriggerTarget)
+	n, _ := toNumber(current)
+	e.setVariable(element.TriggerTarget, n+sign*step)
+}
+
+// toggleVariable flips a variable between true and false. "", "0" and
+// "false" count as false, as in conditions.
+func (e *Engine) toggleVariable(name string) {
+	current, _ := e.config.Variables.Value(name)
+	e.setVariable(name, !truthy(formatValue(current)))
+}
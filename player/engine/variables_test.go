@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatWith(t *testing.T) {
+	tests := []struct {
+		format string
+		value  interface{}
+		want   string
+	}{
+		{"%.2f", float64(4.5), "4.50"},
+		{".2f", float64(4.5), "4.50"}, // The % is optional
+		{"%.1f", "3.14159", "3.1"},    // Text holding a number
+		{"%05d", float64(42), "00042"},
+		{"%d", float64(2.9), "2"},
+		{"%d", "17", "17"},
+		{"%d", true, "1"},
+		{"%x", 255, "ff"},
+		{"%e", float64(1500), "1.500000e+03"},
+		{"%t", "yes", "true"},
+		{"%t", float64(0), "false"},
+		{"%-5s", "Ann", "Ann  "},
+		{"%5s", "Ann", "  Ann"},
+		{"%q", "Ann", `"Ann"`},
+		{"%d", "many", "%!d(string=many)"}, // Not a number: formatted as text
+		{"%s", []interface{}{float64(1), "a"}, `[1,"a"]`},
+	}
+	for _, tt := range tests {
+		if got := formatWith(tt.format, tt.value); got != tt.want {
+			t.Errorf("formatWith(%q, %#v) = %q, want %q", tt.format, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestLookupPath(t *testing.T) {
+	value := map[string]interface{}{
+		"user":  map[string]interface{}{"Name": "Ann", "tags": []interface{}{"a", "b"}},
+		"games": []interface{}{map[string]interface{}{"title": "Tetris"}},
+	}
+	tests := []struct {
+		path string
+		want interface{}
+		ok   bool
+	}{
+		{"user.Name", "Ann", true},
+		{"user.name", "Ann", true}, // Keys ignore case
+		{"user.tags.1", "b", true},
+		{"games.0.title", "Tetris", true},
+		{"games.1.title", nil, false},
+		{"games.-1", nil, false},
+		{"games.first", nil, false},
+		{"user.age", nil, false},
+		{"user.Name.first", nil, false}, // Into a string
+	}
+	for _, tt := range tests {
+		got, ok := lookupPath(value, strings.Split(tt.path, "."))
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("lookupPath(%q) = %v, %v; want %v, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	// Typed values are walked in their JSON form.
+	items := []CollapsedListItem{{Title: "Doom"}}
+	if got, ok := lookupPath(items, []string{"0", "title"}); !ok || got != "Doom" {
+		t.Errorf("lookupPath into []CollapsedListItem = %v, %v; want Doom, true", got, ok)
+	}
+}
+
+func TestSubstituteVariables(t *testing.T) {
+	config := &Config{Variables: Variables{Custom: map[string]interface{}{
+		"name":  "Ann",
+		"empty": "",
+		"price": float64(4.5),
+		"score": float64(7),
+		"theme": "dark",
+		"user":  map[string]interface{}{"name": "Ann"},
+		"games": []interface{}{map[string]interface{}{"title": "Tetris"}},
+		"ok":    true,
+	}}}
+	tests := []struct {
+		text string
+		want string
+	}{
+		// Plain references and paths
+		{"Hi $name", "Hi Ann"},
+		{"Hi $NAME", "Hi Ann"},
+		{"$user.name plays $games.0.title", "Ann plays Tetris"},
+		{"$user", `{"name":"Ann"}`},
+		{"$ok", "true"},
+		{"$price", "4.5"},
+
+		// Formats
+		{"${price:%.2f}", "4.50"},
+		{"${score:%03d}", "007"},
+		{"${user.name:%q}", `"Ann"`},
+
+		// Defaults
+		{"${nickname|Guest}", "Guest"},
+		{"${empty|Guest}", "Guest"},
+		{"${name|Guest}", "Ann"},
+		{"${nickname|}", ""},
+		{"${missing:%05d|0}", "0"},
+		{"${score:%05d|0}", "00007"},
+		{"${games.3.title|none}", "none"},
+		{"$nickname", "MISSING_VAR"},
+		{"${nickname}", "MISSING_VAR"},
+
+		// A ":-" starts a format, such as a left-justified one, not a
+		// shell-style default
+		{"[${name:-5s}]", "[Ann  ]"},
+
+		// The longest prefix naming a plain value wins
+		{"$theme.png", "dark.png"},
+		{"bg/$theme.large.png", "bg/dark.large.png"},
+		{"$user.name.txt", "Ann.txt"},
+		{"$user.age", "MISSING_VAR"},     // A path into an object that isn't there
+		{"$games.0.year", "MISSING_VAR"}, // Or into a list
+		{"$nothing.png", "MISSING_VAR"},
+	}
+	for _, tt := range tests {
+		if got := substituteVariables(tt.text, config); got != tt.want {
+			t.Errorf("substituteVariables(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSubstituteVariablesShellQuoted(t *testing.T) {
+	config := &Config{Variables: Variables{Custom: map[string]interface{}{
+		"rom":   "My Game (USA).zip",
+		"quote": "it's",
+	}}}
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"unzip $rom", `unzip 'My Game (USA).zip'`},
+		{"echo $quote", `echo 'it'\''s'`},
+		{"echo ${missing|a b}", `echo 'a b'`},
+		{`for f in "$@"; do echo $1 $HOME; done`, `for f in "$@"; do echo $1 $HOME; done`}, // The shell's own
+	}
+	for _, tt := range tests {
+		if got := substituteVariablesWith(tt.text, config, shellQuote); got != tt.want {
+			t.Errorf("substituteVariablesWith(%q, shellQuote) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// options control how the player runs a config. Every flag has a JUKAGUI_*
+// environment variable so launch.sh can set them too; flags win over the
+// environment.
+type options struct {
+	config     string
+	fullscreen bool
+	width      int
+	height     int
+	scene      string
+	watch      bool
+	state      string
+	policy     string
+	logLevel   string
+	logFile    string
+	logMaxSize int
+}
+
+// parseOptions reads the player's flags from args. It prints usage and
+// returns an error for unknown flags and malformed values.
+func parseOptions(args []string) (*options, error) {
+	fs := flag.NewFlagSet("JukaGUI", flag.ContinueOnError)
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintln(out, "Usage: JukaGUI [flags]")
+		fmt.Fprintln(out, "       JukaGUI render [flags]")
+		fmt.Fprintln(out, "       JukaGUI validate [flags]")
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "Flags (each can also be set with the environment variable in brackets):")
+		fs.PrintDefaults()
+	}
+
+	var env envDefaults
+	opts := &options{}
+	fs.StringVar(&opts.config, "config", env.String("JUKAGUI_CONFIG", "jukaconfig.json"), "config file to run [JUKAGUI_CONFIG]")
+	fs.BoolVar(&opts.fullscreen, "fullscreen", env.Bool("JUKAGUI_FULLSCREEN", false), "open a fullscreen window instead of a normal one [JUKAGUI_FULLSCREEN]")
+	fs.IntVar(&opts.width, "width", env.Int("JUKAGUI_WIDTH", 0), "window width (default the config's resolution) [JUKAGUI_WIDTH]")
+	fs.IntVar(&opts.height, "height", env.Int("JUKAGUI_HEIGHT", 0), "window height (default the config's resolution) [JUKAGUI_HEIGHT]")
+	fs.StringVar(&opts.scene, "scene", env.String("JUKAGUI_SCENE", ""), "name of the scene to start in (default the first one) [JUKAGUI_SCENE]")
+	fs.BoolVar(&opts.watch, "watch", env.Bool("JUKAGUI_WATCH", false), "reload the config when it changes [JUKAGUI_WATCH]")
+	fs.StringVar(&opts.state, "state", env.String("JUKAGUI_STATE", ""), "file for persistent variables (default next to the config, or under $XDG_STATE_HOME) [JUKAGUI_STATE]")
+	fs.StringVar(&opts.policy, "policy", env.String("JUKAGUI_POLICY", ""), "security policy file limiting the commands and files the app may use [JUKAGUI_POLICY]")
+	fs.StringVar(&opts.logLevel, "log-level", env.String("JUKAGUI_LOG_LEVEL", "warn"), "debug, info, warn, error or off [JUKAGUI_LOG_LEVEL]")
+	fs.StringVar(&opts.logFile, "log-file", env.String("JUKAGUI_LOG_FILE", ""), "append the log to this file instead of stderr [JUKAGUI_LOG_FILE]")
+	fs.IntVar(&opts.logMaxSize, "log-max-size", env.Int("JUKAGUI_LOG_MAX_SIZE", 0), "rotate the log file when it grows past this many KiB, 0 for never [JUKAGUI_LOG_MAX_SIZE]")
+
+	if env.err != nil {
+		fmt.Fprintln(fs.Output(), env.err)
+		fs.Usage()
+		return nil, env.err
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		err := fmt.Errorf("unexpected argument %q", fs.Arg(0))
+		fmt.Fprintln(fs.Output(), err)
+		fs.Usage()
+		return nil, err
+	}
+	if (opts.width > 0) != (opts.height > 0) {
+		err := fmt.Errorf("-width and -height must be given together")
+		fmt.Fprintln(fs.Output(), err)
+		return nil, err
+	}
+	if _, ok := logLevels[strings.ToLower(opts.logLevel)]; !ok {
+		err := fmt.Errorf("unknown log level %q", opts.logLevel)
+		fmt.Fprintln(fs.Output(), err)
+		return nil, err
+	}
+	return opts, nil
+}
+
+// envDefaults reads flag defaults from the environment and remembers the
+// first malformed value.
+type envDefaults struct {
+	err error
+}
+
+func (d *envDefaults) String(name, fallback string) string {
+	if value, ok := os.LookupEnv(name); ok {
+		return value
+	}
+	return fallback
+}
+
+func (d *envDefaults) Bool(name string, fallback bool) bool {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		d.fail(name, value)
+		return fallback
+	}
+	return b
+}
+
+func (d *envDefaults) Int(name string, fallback int) int {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		d.fail(name, value)
+		return fallback
+	}
+	return n
+}
+
+func (d *envDefaults) fail(name, value string) {
+	if d.err == nil {
+		d.err = fmt.Errorf("invalid value %q for %s", value, name)
+	}
+}
+
+// logLevels maps -log-level values to slog levels. "off" is above every
+// level the player logs at.
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+	"off":   slog.LevelError + 100,
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"jukagui/JukaGUI/engine"
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// renderCommand implements `JukaGUI render`: it draws every scene of a
+// config offscreen and writes one PNG per scene.
+func renderCommand(args []string) int {
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	configPath := fs.String("config", "jukaconfig.json", "config file to render")
+	outDir := fs.String("out", "snapshots", "directory to write the PNG files to")
+	width := fs.Int("width", 0, "output width in pixels (default the config's resolution)")
+	height := fs.Int("height", 0, "output height in pixels (default the config's resolution)")
+	selected := fs.Int("select", -2, "index of the element to focus in every scene (-1 for none, default first selectable)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: JukaGUI render [flags]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	config, err := engine.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Println("Error loading config:", err)
+		return 1
+	}
+
+	// Paths in the config are relative to the config file, just like when
+	// launch.sh starts the player next to it.
+	outPath, err := filepath.Abs(*outDir)
+	if err != nil {
+		fmt.Println("Error", err)
+		return 1
+	}
+	if err := os.MkdirAll(outPath, 0o755); err != nil {
+		fmt.Println("Error creating output directory:", err)
+		return 1
+	}
+	if err := os.Chdir(filepath.Dir(*configPath)); err != nil {
+		fmt.Println("Error", err)
+		return 1
+	}
+
+	if err := engine.InitHeadless(); err != nil {
+		fmt.Printf("Error %v\n", err)
+		return 1
+	}
+	defer engine.Quit()
+
+	w, h := config.LogicalSize()
+	if *width > 0 && *height > 0 {
+		w, h = int32(*width), int32(*height)
+	}
+	backend, err := engine.NewOffscreenBackend(w, h)
+	if err != nil {
+		fmt.Printf("Error %v\n", err)
+		return 1
+	}
+
+	e := engine.New(config)
+	e.OpenBackend(backend)
+	e.SetCommandsEnabled(false)
+	defer e.Close()
+
+	for i, scene := range config.Scenes {
+		if err := e.SetScene(i); err != nil {
+			fmt.Printf("Error %v\n", err)
+			return 1
+		}
+		if *selected != -2 {
+			if err := e.Select(*selected); err != nil {
+				fmt.Printf("Scene %q: %v\n", scene.Name, err)
+			}
+		}
+		e.Render()
+
+		name := fmt.Sprintf("%02d-%s.png", i, unsafeFileChars.ReplaceAllString(scene.Name, "_"))
+		if err := backend.SavePNG(filepath.Join(outPath, name)); err != nil {
+			fmt.Println("Error writing snapshot:", err)
+			return 1
+		}
+		fmt.Println("Wrote", filepath.Join(outPath, name))
+	}
+	return 0
+}
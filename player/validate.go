@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"jukagui/JukaGUI/engine"
+)
+
+// validateCommand implements `JukaGUI validate`: it checks a config and
+// prints every problem it finds. The exit status is 1 if any of them is an
+// error.
+func validateCommand(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	configPath := fs.String("config", "jukaconfig.json", "config file to check")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: JukaGUI validate [flags]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	config, err := engine.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Println("Error loading config:", err)
+		return 1
+	}
+
+	problems := engine.Validate(config, filepath.Dir(*configPath))
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if engine.HasErrors(problems) {
+		return 1
+	}
+	fmt.Printf("%s: OK (%d warnings)\n", *configPath, len(problems))
+	return 0
+}
@@ -1269,6 +1269,10 @@ function createJukaApp() {
     title: document.getElementById('title').value,
     author: document.getElementById('author').value,
     description: document.getElementById('description').value,
+    resolution: {
+      width: canvasWidth,
+      height: canvasHeight
+    },
     variables: {
       ...variables,
       backgroundImage: backgroundPath,